@@ -1,12 +1,13 @@
 package uidgenerator
 
 import (
-	"errors"
+	"fmt"
 )
 
 /*
 Allocate 64 bits for the UID(long)<br>
-sign (fixed 1bit) -> deltaSecond -> workerId -> sequence(within the same second)
+sign (fixed 1bit) -> deltaTimestamp -> datacenterId -> workerId -> sequence(within the same second) by default,
+or the segments of any Layout
 */
 
 const (
@@ -17,52 +18,61 @@ const (
 )
 
 type bitsAllocator struct {
-	// Bits for [sign-> second-> workId-> sequence]
-	SignBits      int
-	TimestampBits int
-	WorkerIdBits  int
-	SequenceBits  int
-	// Max value for timestamp & workId & sequence
-	MaxDeltaSeconds int64
-	MaxWorkerId     int64
-	MaxSequence     int64
+	layout *Layout
+	// Segments for timestamp & workId & sequence
+	timestamp LayoutSegment
+	workerId  LayoutSegment
+	sequence  LayoutSegment
+	// fixed The bits of the fixed segments, such as datacenterId
+	fixed int64
+	// Max value for timestamp in the time unit & datacenterId & workId & sequence, MaxDatacenterId is 0 without datacenter segment
+	MaxDeltaTimestamp int64
+	MaxDatacenterId   int64
+	MaxWorkerId       int64
+	MaxSequence       int64
 	// Shift for timestamp & workerId
 	TimestampShift int
 	WorkerIdShift  int
 }
 
 /*
-newBitsAllocator Constructor with timestampBits, workerIdBits, sequenceBits<br>
-The highest bit used for sign, so <code>63</code> bits for timestampBits, workerIdBits, sequenceBits
+newBitsAllocator Constructor with the layout and the values of its fixed segments<br>
+The segments other than timestamp, workerId and sequence are fixed, their values default as 0
 */
-func newBitsAllocator(timestampBits, workerIdBits, sequenceBits int) (*bitsAllocator, error) {
-	// make sure allocated 64 bits
-	allocatorTotalBits := signBits + timestampBits + workerIdBits + sequenceBits
-	if allocatorTotalBits != totalBits {
-		return nil, errors.New("allocate not enough 64 bits")
+func newBitsAllocator(layout *Layout, values map[string]int64) (*bitsAllocator, error) {
+	b := bitsAllocator{layout: layout}
+	for name, value := range values {
+		segment, ok := layout.Segment(name)
+		if !ok {
+			return nil, fmt.Errorf("segment %s is not in the layout", name)
+		}
+		switch name {
+		case SegmentTimestamp, SegmentWorkerId, SegmentSequence:
+			return nil, fmt.Errorf("segment %s can't be fixed", name)
+		}
+		if value < 0 || value > segment.Max {
+			return nil, fmt.Errorf("%s %d exceeds the max %d", name, value, segment.Max)
+		}
+		b.fixed |= segment.Allocate(value)
 	}
-	/*
-		initialize bits
-		initialize max value
-		initialize shift
-	*/
-	return &bitsAllocator{
-		SignBits:        signBits,
-		TimestampBits:   timestampBits,
-		WorkerIdBits:    workerIdBits,
-		SequenceBits:    sequenceBits,
-		MaxDeltaSeconds: ^(-1 << timestampBits),
-		MaxWorkerId:     ^(-1 << workerIdBits),
-		MaxSequence:     ^(-1 << sequenceBits),
-		TimestampShift:  workerIdBits + sequenceBits,
-		WorkerIdShift:   sequenceBits,
-	}, nil
+	b.timestamp, _ = layout.Segment(SegmentTimestamp)
+	b.workerId, _ = layout.Segment(SegmentWorkerId)
+	b.sequence, _ = layout.Segment(SegmentSequence)
+	if datacenterId, ok := layout.Segment(SegmentDatacenterId); ok {
+		b.MaxDatacenterId = datacenterId.Max
+	}
+	b.MaxDeltaTimestamp = b.timestamp.Max
+	b.MaxWorkerId = b.workerId.Max
+	b.MaxSequence = b.sequence.Max
+	b.TimestampShift = b.timestamp.Shift
+	b.WorkerIdShift = b.workerId.Shift
+	return &b, nil
 }
 
 /*
-Allocate bits for UID according to delta seconds & workerId & sequence<br>
+Allocate bits for UID according to delta timestamp & workerId & sequence, with the fixed segments<br>
 <b>Note that: </b>The highest bit will always be 0 for sign
 */
-func (b *bitsAllocator) allocate(deltaSeconds, workerId, sequence int64) int64 {
-	return (deltaSeconds << b.TimestampShift) | (workerId << b.WorkerIdShift) | sequence
+func (b *bitsAllocator) allocate(deltaTimestamp, workerId, sequence int64) int64 {
+	return b.timestamp.Allocate(deltaTimestamp) | b.fixed | b.workerId.Allocate(workerId) | b.sequence.Allocate(sequence)
 }
@@ -2,6 +2,7 @@ package uidgenerator
 
 import (
 	"errors"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -15,8 +16,8 @@ There are two kinds of executors: one for scheduled padding, the other for paddi
 type bufferPaddingExecutor struct {
 	// Whether buffer padding is running
 	running atomic.Bool
-	// We can borrow UIDs from the future, here store the last second we have consumed.
-	lastSecond *paddedAtomicLong
+	// We can borrow UIDs from the future, here store the last timestamp we have consumed, in the time unit.
+	lastTimestamp *paddedAtomicLong
 	// ringBuffer
 	ringBuffer *ringBuffer
 	// bufferedUidProvider
@@ -24,7 +25,8 @@ type bufferPaddingExecutor struct {
 	// Schedule interval Unit as seconds
 	scheduleInterval int64
 
-	stop chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 /*
@@ -33,22 +35,24 @@ Constructor with ringBuffer, bufferedUidProvider, and whether you use schedule p
 
 ringBuffer ringBuffer
 uidProvider bufferedUidProvider
+clock Clock
+timeUnit TimeUnit
 usingSchedule bool
 */
-func newBufferPaddingExecutor(ringBuffer *ringBuffer, uidProvider bufferedUidProvider, usingSchedule bool) *bufferPaddingExecutor {
+func newBufferPaddingExecutor(ringBuffer *ringBuffer, uidProvider bufferedUidProvider, clock Clock, timeUnit TimeUnit, usingSchedule bool) *bufferPaddingExecutor {
 	bufferPaddingExecutor := bufferPaddingExecutor{
-		running:     atomic.Bool{},
-		lastSecond:  newPaddedAtomicLong(time.Now().Unix()),
-		ringBuffer:  ringBuffer,
-		uidProvider: uidProvider,
-		stop:        make(chan struct{}),
+		running:       atomic.Bool{},
+		lastTimestamp: newPaddedAtomicLong(timeUnit.timestamp(clock.Now())),
+		ringBuffer:    ringBuffer,
+		uidProvider:   uidProvider,
+		stop:          make(chan struct{}),
 	}
 	return &bufferPaddingExecutor
 }
 
 // Padding buffer fill the slots until to catch the cursor
 func (b *bufferPaddingExecutor) paddingBuffer() {
-	//log.Printf("Ready to padding buffer lastSecond:%d. %s", b.lastSecond.Load(), b.ringBuffer.string())
+	//log.Printf("Ready to padding buffer lastTimestamp:%d. %s", b.lastTimestamp.Load(), b.ringBuffer.string())
 	// is still running
 	if !b.running.CompareAndSwap(false, true) {
 		//log.Printf("Padding buffer is still running. %s", b.ringBuffer.string())
@@ -58,7 +62,7 @@ func (b *bufferPaddingExecutor) paddingBuffer() {
 	// fill the rest slots until to catch the cursor
 	isFullRingBuffer := false
 	for !isFullRingBuffer {
-		provideIds := b.uidProvider.provide(b.lastSecond.Add(1))
+		provideIds := b.uidProvider.provide(b.lastTimestamp.Add(1))
 		for i := 0; i < len(provideIds); i++ {
 			if isFullRingBuffer = !b.ringBuffer.put(provideIds[i]); isFullRingBuffer {
 				break
@@ -69,7 +73,7 @@ func (b *bufferPaddingExecutor) paddingBuffer() {
 
 	// not running now
 	b.running.CompareAndSwap(true, false)
-	//log.Printf("end to padding buffer lastSecond:%d. %s", b.lastSecond.Load(), b.ringBuffer.string())
+	//log.Printf("end to padding buffer lastTimestamp:%d. %s", b.lastTimestamp.Load(), b.ringBuffer.string())
 }
 
 func (b *bufferPaddingExecutor) asyncPadding() {
@@ -94,9 +98,11 @@ func (b *bufferPaddingExecutor) start() {
 	}
 }
 
-// Shutdown executors
+// Shutdown executors, it's safe to shut down more than once
 func (b *bufferPaddingExecutor) shutdown() {
-	b.stop <- struct{}{}
+	b.stopOnce.Do(func() {
+		close(b.stop)
+	})
 }
 
 func (b *bufferPaddingExecutor) setScheduleInterval(scheduleInterval int64) error {
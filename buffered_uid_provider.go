@@ -1,7 +1,7 @@
 package uidgenerator
 
 type bufferedUidProvider interface {
-	// Provide Provides UID in one second
-	provide(momentInSecond int64) []int64
+	// Provide Provides UID in one timestamp of the time unit
+	provide(timestamp int64) []int64
 	recycle(list []int64)
 }
@@ -1,6 +1,7 @@
 package uidgenerator
 
 import (
+	"context"
 	"log"
 )
 
@@ -26,7 +27,7 @@ const (
 )
 
 type CachedUidGenerator struct {
-	DefaultUidGenerator
+	*DefaultUidGenerator
 	// ringBuffer size grow arg
 	boostPower int
 	// padding
@@ -75,7 +76,7 @@ func WithRejectedTakeBufferHandler(rejectedTakeBufferHandler RejectedTakeBufferH
 
 func NewCachedUidGenerator(defaultUidGenerator *DefaultUidGenerator, opts ...OptionCached) (*CachedUidGenerator, error) {
 	uidGenerator := CachedUidGenerator{
-		DefaultUidGenerator: *defaultUidGenerator,
+		DefaultUidGenerator: defaultUidGenerator,
 		boostPower:          defaultBoostPower,
 		paddingFactor:       defaultPaddingPercent,
 		scheduleInterval:    0,
@@ -93,13 +94,20 @@ func NewCachedUidGenerator(defaultUidGenerator *DefaultUidGenerator, opts ...Opt
 	log.Printf("initialized ring buffer size:%d, paddingFactor:%d", bufferSize, uidGenerator.paddingFactor)
 	// initialize RingBufferPaddingExecutor
 	usingSchedule := uidGenerator.scheduleInterval != 0
-	bufferPaddingExecutor := newBufferPaddingExecutor(ringBuffer, newDefaultBufferPidProvider(int(uidGenerator.bitsAllocator.MaxSequence+1), uidGenerator.bitsAllocator, uidGenerator.epochSeconds, uidGenerator.workerId), usingSchedule)
+	bufferPaddingExecutor := newBufferPaddingExecutor(ringBuffer, newDefaultBufferPidProvider(int(uidGenerator.bitsAllocator.MaxSequence+1), uidGenerator.bitsAllocator, uidGenerator.epochOf, uidGenerator.workerId), uidGenerator.clock, uidGenerator.timeUnit, usingSchedule)
 	if usingSchedule {
 		err := bufferPaddingExecutor.setScheduleInterval(uidGenerator.scheduleInterval)
 		if err != nil {
 			return nil, err
 		}
 	}
+	// never borrow the timestamps used by the previous owner of the worker id
+	uidGenerator.mutex.Lock()
+	if uidGenerator.lastTimestamp > bufferPaddingExecutor.lastTimestamp.Load() {
+		bufferPaddingExecutor.lastTimestamp.Store(uidGenerator.lastTimestamp)
+	}
+	uidGenerator.bufferedLastTimestamp = bufferPaddingExecutor.lastTimestamp
+	uidGenerator.mutex.Unlock()
 	log.Printf("initialized bufferPaddingExecutor. Using schdule:%v, interval:%d", usingSchedule, uidGenerator.scheduleInterval)
 	uidGenerator.bufferPaddingExecutor = bufferPaddingExecutor
 	uidGenerator.ringBuffer.bufferPaddingExecutor = bufferPaddingExecutor
@@ -118,9 +126,18 @@ func NewCachedUidGenerator(defaultUidGenerator *DefaultUidGenerator, opts ...Opt
 }
 
 func (c *CachedUidGenerator) GetUID() (int64, error) {
+	if err := c.checkWorkerLease(); err != nil {
+		return 0, err
+	}
 	return c.ringBuffer.take()
 }
 
+// Shutdown Stop padding the ringBuffer, save the last timestamp and release the worker id
+func (c *CachedUidGenerator) Shutdown(ctx context.Context) error {
+	c.bufferPaddingExecutor.shutdown()
+	return c.DefaultUidGenerator.Shutdown(ctx)
+}
+
 func (c *CachedUidGenerator) ParseUID(uid int64) string {
 	return c.DefaultUidGenerator.ParseUID(uid)
 }
@@ -0,0 +1,129 @@
+package uidgenerator
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock Represents the source of the current time for generating and padding uid
+type Clock interface {
+	Now() time.Time
+}
+
+// sleeper A Clock which decides how to sleep on it, such as FakeClock
+type sleeper interface {
+	Sleep(d time.Duration)
+}
+
+// sleep Sleep d on clock, in real time unless the clock is a sleeper
+func sleep(clock Clock, d time.Duration) {
+	if s, ok := clock.(sleeper); ok {
+		s.Sleep(d)
+		return
+	}
+	time.Sleep(d)
+}
+
+// systemClock The clock of the system, the default Clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+/*
+FakeClock
+A Clock controlled by the tests, it stands still until Set or Advance.
+Sleeping on it, such as ClockBackwardsWait, advances it at once.
+The clock may be set backwards or far into the future to simulate the clock moving backwards or the time bits running out.
+*/
+type FakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (f *FakeClock) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+// Set Set the clock to now, backwards is allowed
+func (f *FakeClock) Set(now time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = now
+}
+
+// Advance Move the clock by d, negative d moves it backwards
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Sleep Advance the clock by d at once instead of sleeping
+func (f *FakeClock) Sleep(d time.Duration) {
+	f.Advance(d)
+}
+
+// monotonicSlewInterval The interval between slewing towards a wall clock ahead by more than the max drift
+const monotonicSlewInterval = time.Second
+
+/*
+MonotonicClock
+Anchors the wall time at creation and advances it by the monotonic clock, a wall clock stepping backwards never reaches the generator.
+It re-syncs with the wall clock only forward. When the wall clock is ahead by no more than maxDrift,
+such as the monotonic clock running slow or NTP correcting a slow wall clock, it jumps to the wall clock.
+Larger jumps forward, such as after the host is suspended, are slewed towards by maxDrift every second.
+*/
+type MonotonicClock struct {
+	mutex sync.Mutex
+	// wall The wall clock to anchor and re-sync with
+	wall     Clock
+	maxDrift time.Duration
+	// anchor The wall time anchored, and the monotonic reading at that moment
+	anchor          time.Time
+	anchorMonotonic time.Time
+	// slewed The monotonic reading of the last slew
+	slewed time.Time
+}
+
+// NewMonotonicClock Anchor the wall time now, and re-sync with the wall clock forward up to maxDrift
+func NewMonotonicClock(maxDrift time.Duration) *MonotonicClock {
+	return newMonotonicClock(systemClock{}, maxDrift)
+}
+
+func newMonotonicClock(wall Clock, maxDrift time.Duration) *MonotonicClock {
+	return &MonotonicClock{
+		wall:            wall,
+		maxDrift:        maxDrift,
+		anchor:          wall.Now().Round(0),
+		anchorMonotonic: time.Now(),
+	}
+}
+
+func (m *MonotonicClock) Now() time.Time {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	monotonic := time.Now()
+	now := m.anchor.Add(monotonic.Sub(m.anchorMonotonic))
+	wall := m.wall.Now().Round(0)
+	drift := wall.Sub(now)
+	if drift <= 0 {
+		return now
+	}
+	if drift <= m.maxDrift {
+		m.anchor, m.anchorMonotonic = wall, monotonic
+		return wall
+	}
+	if !m.slewed.IsZero() && monotonic.Sub(m.slewed) < monotonicSlewInterval {
+		return now
+	}
+	m.anchor, m.anchorMonotonic, m.slewed = now.Add(m.maxDrift), monotonic, monotonic
+	return m.anchor
+}
@@ -0,0 +1,79 @@
+package uidgenerator
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+ClockBackwardsStrategy
+How DefaultUidGenerator deals with the clock behind the last timestamp, such as NTP slewing the clock back.
+Each strategy gives up once the drift exceeds the max drift of WithClockBackwardsStrategy,
+CachedUidGenerator borrows the future timestamps always, it's not affected.
+*/
+type ClockBackwardsStrategy int
+
+const (
+	// ClockBackwardsFailFast Refuse to generate uid until the clock catches up, the default
+	ClockBackwardsFailFast ClockBackwardsStrategy = iota
+	// ClockBackwardsWait Wait for the clock to catch up, the other callers of GetUID are blocked meanwhile
+	ClockBackwardsWait
+	// ClockBackwardsBorrow Continue from the last timestamp with the spare sequence, then borrow the next timestamps ahead of the clock
+	ClockBackwardsBorrow
+	// ClockBackwardsBackupWorker Switch to a backup worker id which has not used the current timestamp
+	ClockBackwardsBackupWorker
+)
+
+func (c ClockBackwardsStrategy) String() string {
+	switch c {
+	case ClockBackwardsFailFast:
+		return "FailFast"
+	case ClockBackwardsWait:
+		return "Wait"
+	case ClockBackwardsBorrow:
+		return "Borrow"
+	case ClockBackwardsBackupWorker:
+		return "BackupWorker"
+	default:
+		return fmt.Sprintf("ClockBackwardsStrategy(%d)", int(c))
+	}
+}
+
+// ClockBackwardsError The clock is behind the last timestamp by Drift, and the strategy gives up
+type ClockBackwardsError struct {
+	Drift    time.Duration
+	Strategy ClockBackwardsStrategy
+}
+
+func (c *ClockBackwardsError) Error() string {
+	return fmt.Sprintf("clock moved backwards. Refusing for %v, strategy %v", c.Drift, c.Strategy)
+}
+
+/*
+ClockBackwardsStats
+Metrics of the clock behind the last timestamp, counted by GetUID.
+Borrow counts every uid generated ahead of the clock, the clock may stay behind for many calls.
+*/
+type ClockBackwardsStats struct {
+	// Count Times the clock is found behind the last timestamp
+	Count int64
+	// MaxDrift The max drift seen
+	MaxDrift time.Duration
+	// Waited Times of waiting for the clock to catch up by ClockBackwardsWait
+	Waited int64
+	// Borrowed Uid generated at a timestamp ahead of the clock by ClockBackwardsBorrow
+	Borrowed int64
+	// Switched Times of switching to a backup worker id by ClockBackwardsBackupWorker
+	Switched int64
+	// Refused Times of ClockBackwardsError returned
+	Refused int64
+}
+
+// backupWorker A worker id to switch to by ClockBackwardsBackupWorker, the primary one is kept as a backupWorker once switched
+type backupWorker struct {
+	assigner      WorkerIdAssigner
+	workerId      int64
+	lease         WorkerLease
+	lastTimestamp int64
+	sequence      int64
+}
@@ -0,0 +1,235 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestClockBackwardsFailFast(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(now)
+	defaultUidGenerator, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := defaultUidGenerator.GetUID(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(-time.Second)
+	_, err = defaultUidGenerator.GetUID()
+	var clockBackwardsError *ClockBackwardsError
+	if !errors.As(err, &clockBackwardsError) || clockBackwardsError.Drift != time.Second {
+		t.Fatalf("err is %v, want ClockBackwardsError of 1s", err)
+	}
+	if stats := defaultUidGenerator.ClockBackwardsStats(); stats.Count != 1 || stats.Refused != 1 || stats.MaxDrift != time.Second {
+		t.Errorf("stats %+v, want 1 refused of 1s", stats)
+	}
+}
+
+func TestClockBackwardsWait(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(now)
+	defaultUidGenerator, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithClock(clock),
+		WithClockBackwardsStrategy(ClockBackwardsWait, 500*time.Millisecond), WithTimeUnit(TimeUnitMillisecond), WithBits(41, 10, 12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := defaultUidGenerator.GetUID(); err != nil {
+		t.Fatal(err)
+	}
+	// the clock catches up while waiting, sleeping on the fake clock advances it
+	clock.Advance(-100 * time.Millisecond)
+	if _, err := defaultUidGenerator.GetUID(); err != nil {
+		t.Fatal(err)
+	}
+	if clock.Now().Before(now) {
+		t.Errorf("clock is %v after waiting, want caught up with %v", clock.Now(), now)
+	}
+	// beyond the max drift
+	clock.Set(now.Add(-time.Second))
+	var clockBackwardsError *ClockBackwardsError
+	if _, err := defaultUidGenerator.GetUID(); !errors.As(err, &clockBackwardsError) || clockBackwardsError.Drift != time.Second {
+		t.Fatalf("err is %v, want ClockBackwardsError of 1s", err)
+	}
+	if stats := defaultUidGenerator.ClockBackwardsStats(); stats.Count != 2 || stats.Waited != 1 || stats.Refused != 1 {
+		t.Errorf("stats %+v, want 2 times, 1 waited and 1 refused", stats)
+	}
+}
+
+func TestClockBackwardsBorrow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(now)
+	defaultUidGenerator, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithClock(clock),
+		WithClockBackwardsStrategy(ClockBackwardsBorrow, 2*time.Second), WithBits(28, 30, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestampOf := func(uid int64) int64 {
+		return uid>>defaultUidGenerator.bitsAllocator.TimestampShift + defaultUidGenerator.epochTimestamp
+	}
+	last, err := defaultUidGenerator.GetUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1s behind, the rest of the second and the next second are borrowed
+	clock.Advance(-time.Second)
+	for i := int64(1); i < 2*(defaultUidGenerator.bitsAllocator.MaxSequence+1); i++ {
+		uid, err := defaultUidGenerator.GetUID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uid <= last {
+			t.Fatalf("uid %d is not after %d", uid, last)
+		}
+		last = uid
+	}
+	if timestampOf(last) != now.Unix()+1 {
+		t.Fatalf("uid second %d, want %d", timestampOf(last), now.Unix()+1)
+	}
+	// borrowing one more second exceeds the max drift
+	var clockBackwardsError *ClockBackwardsError
+	if _, err := defaultUidGenerator.GetUID(); !errors.As(err, &clockBackwardsError) {
+		t.Fatalf("err is %v, want ClockBackwardsError", err)
+	}
+	clock.Set(now.Add(2 * time.Second))
+	uid, err := defaultUidGenerator.GetUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uid <= last {
+		t.Errorf("uid %d is not after %d", uid, last)
+	}
+	if stats := defaultUidGenerator.ClockBackwardsStats(); stats.Borrowed != 2*(defaultUidGenerator.bitsAllocator.MaxSequence+1)-1 {
+		t.Errorf("stats %+v, want %d borrowed", stats, 2*(defaultUidGenerator.bitsAllocator.MaxSequence+1)-1)
+	}
+}
+
+func TestClockBackwardsBorrowNotBehind(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(now)
+	defaultUidGenerator, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithClock(clock),
+		WithClockBackwardsStrategy(ClockBackwardsBorrow, 2*time.Second), WithBits(28, 30, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i <= defaultUidGenerator.bitsAllocator.MaxSequence; i++ {
+		if _, err := defaultUidGenerator.GetUID(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the sequence is used up but the clock never moved backwards, wait for the next second instead of borrowing it
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		clock.Advance(time.Second)
+	}()
+	for i := 0; i < 2; i++ {
+		if _, err := defaultUidGenerator.GetUID(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := defaultUidGenerator.ClockBackwardsStats(); stats.Count != 0 || stats.Borrowed != 0 {
+		t.Errorf("stats %+v, want the clock never behind", stats)
+	}
+}
+
+func TestClockBackwardsBackupWorker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(now)
+	backups := []*fixedWorkerIdAssigner{{workerId: 2}, {workerId: 3}}
+	defaultUidGenerator, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{workerId: 1}, WithClock(clock),
+		WithClockBackwardsStrategy(ClockBackwardsBackupWorker, time.Minute), WithBackupWorkerIdAssigners(backups[0], backups[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workerIdOf := func(uid int64) int64 {
+		return uid >> defaultUidGenerator.bitsAllocator.WorkerIdShift & defaultUidGenerator.bitsAllocator.MaxWorkerId
+	}
+	for _, want := range []int64{1, 2, 3} {
+		uid, err := defaultUidGenerator.GetUID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if workerIdOf(uid) != want {
+			t.Errorf("worker id is %d, want %d", workerIdOf(uid), want)
+		}
+		clock.Advance(-time.Second)
+	}
+	// every worker has used the current second
+	var clockBackwardsError *ClockBackwardsError
+	if _, err := defaultUidGenerator.GetUID(); !errors.As(err, &clockBackwardsError) {
+		t.Fatalf("err is %v, want ClockBackwardsError", err)
+	}
+	// the backup worker goes on once the clock catches up
+	clock.Set(now.Add(time.Second))
+	uid, err := defaultUidGenerator.GetUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workerIdOf(uid) != 3 {
+		t.Errorf("worker id is %d, want 3", workerIdOf(uid))
+	}
+	if stats := defaultUidGenerator.ClockBackwardsStats(); stats.Switched != 2 || stats.Refused != 1 {
+		t.Errorf("stats %+v, want 2 switched and 1 refused", stats)
+	}
+	if err := defaultUidGenerator.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !backups[0].released || !backups[1].released {
+		t.Error("backup worker ids are not released")
+	}
+}
+
+func TestClockBackwardsBackupWorkerLease(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	backup, err := NewRedisWorkerIdAssigner(client, WithRedisRange(2, 2),
+		WithRedisLeaseTTL(time.Second), WithRedisRenewInterval(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(now)
+	lost := make(chan error, 1)
+	defaultUidGenerator, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{workerId: 1}, WithClock(clock),
+		WithClockBackwardsStrategy(ClockBackwardsBackupWorker, time.Minute), WithBackupWorkerIdAssigners(backup),
+		WithLastTimestampSaveInterval(50*time.Millisecond), WithWorkerLeaseLostHandler(func(err error) {
+			lost <- err
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer defaultUidGenerator.Shutdown(context.Background())
+	if _, err := defaultUidGenerator.GetUID(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(-time.Second)
+	if _, err := defaultUidGenerator.GetUID(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the backup worker id keeps its own last timestamp while running
+	time.Sleep(200 * time.Millisecond)
+	if lastTimestamp, err := backup.LoadLastTimestamp(context.Background(), 2); err != nil || !lastTimestamp.Equal(now.Add(-time.Second)) {
+		t.Errorf("last timestamp of the backup is %v, %v, want %v", lastTimestamp, err, now.Add(-time.Second))
+	}
+
+	// the lease of the backup worker id is watched like the primary one
+	server.Set("uid:worker:2", "another")
+	select {
+	case err := <-lost:
+		if !errors.Is(err, ErrWorkerLeaseLost) {
+			t.Errorf("err is %v, want ErrWorkerLeaseLost", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lost backup lease is not reported")
+	}
+	if _, err := defaultUidGenerator.GetUID(); !errors.Is(err, ErrWorkerLeaseLost) {
+		t.Errorf("err is %v, want ErrWorkerLeaseLost", err)
+	}
+}
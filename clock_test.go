@@ -0,0 +1,160 @@
+package uidgenerator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(now)
+	defaultUidGenerator, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithClock(clock), WithBits(28, 30, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestampOf := func(uid int64) int64 {
+		return uid>>defaultUidGenerator.bitsAllocator.TimestampShift + defaultUidGenerator.epochTimestamp
+	}
+
+	// the sequence overflows while the clock stalls, it waits for the next second
+	for i := int64(0); i <= defaultUidGenerator.bitsAllocator.MaxSequence; i++ {
+		uid, err := defaultUidGenerator.GetUID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if timestampOf(uid) != now.Unix() {
+			t.Fatalf("uid second %d, want %d", timestampOf(uid), now.Unix())
+		}
+	}
+	next := make(chan int64, 1)
+	go func() {
+		uid, err := defaultUidGenerator.GetUID()
+		if err != nil {
+			t.Error(err)
+		}
+		next <- uid
+	}()
+	select {
+	case <-next:
+		t.Fatal("uid is generated after the sequence overflows in the same second")
+	case <-time.After(100 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	if uid := <-next; timestampOf(uid) != now.Unix()+1 {
+		t.Errorf("uid second %d, want %d", timestampOf(uid), now.Unix()+1)
+	}
+
+	// the clock moves backwards
+	clock.Set(now)
+	if _, err := defaultUidGenerator.GetUID(); err == nil || !strings.Contains(err.Error(), "clock moved backwards") {
+		t.Errorf("err is %v, want clock moved backwards", err)
+	}
+
+	// the time bits run out
+	clock.Set(defaultUidGenerator.ExhaustionTime())
+	clock.Advance(-time.Second)
+	if _, err := defaultUidGenerator.GetUID(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if _, err := defaultUidGenerator.GetUID(); err == nil || !strings.Contains(err.Error(), "exhausted") {
+		t.Errorf("err is %v, want timestamp bits is exhausted", err)
+	}
+
+	// the cached uid are padded from the clock
+	defaultUidGenerator, err = NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithClock(clock))
+	if err == nil {
+		t.Error("the generator is created after the time bits run out")
+	}
+	clock.Set(now)
+	defaultUidGenerator, err = NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedUidGenerator, err := NewCachedUidGenerator(defaultUidGenerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cachedUidGenerator.Shutdown(context.Background())
+	uid, err := cachedUidGenerator.GetUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timestampOf(uid) != now.Unix()+1 {
+		t.Errorf("cached uid second %d, want the next second of the clock %d", timestampOf(uid), now.Unix()+1)
+	}
+}
+
+func TestMonotonicClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	wall := NewFakeClock(start)
+	clock := newMonotonicClock(wall, 5*time.Second)
+	near := func(now, want time.Time) bool {
+		return !now.Before(want) && now.Sub(want) < time.Second
+	}
+
+	// the wall clock steps backwards
+	wall.Advance(-10 * time.Second)
+	if now := clock.Now(); !near(now, start) {
+		t.Errorf("now is %v, want about %v", now, start)
+	}
+	// re-sync forward within the max drift
+	wall.Set(start.Add(2 * time.Second))
+	if now := clock.Now(); !now.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("now is %v, want the wall clock %v", now, start.Add(2*time.Second))
+	}
+	// the jump beyond the max drift is slewed towards by the max drift a second
+	wall.Advance(time.Hour)
+	if now := clock.Now(); !near(now, start.Add(7*time.Second)) {
+		t.Errorf("now is %v, want about %v", now, start.Add(7*time.Second))
+	}
+	if now := clock.Now(); !near(now, start.Add(7*time.Second)) {
+		t.Errorf("now is %v, want about %v before the next slew", now, start.Add(7*time.Second))
+	}
+
+	// both generators survive the wall clock stepping backwards
+	wall = NewFakeClock(time.Now())
+	defaultUidGenerator, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithClock(newMonotonicClock(wall, time.Second)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedUidGenerator, err := NewCachedUidGenerator(defaultUidGenerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cachedUidGenerator.Shutdown(context.Background())
+	timestampOf := func(uid int64) int64 {
+		return uid>>defaultUidGenerator.bitsAllocator.TimestampShift + defaultUidGenerator.epochTimestamp
+	}
+	uidGenerators := []UidGenerator{defaultUidGenerator, cachedUidGenerator}
+	lasts := make([]int64, len(uidGenerators))
+	for i, uidGenerator := range uidGenerators {
+		if lasts[i], err = uidGenerator.GetUID(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wall.Advance(-time.Hour)
+	for i, uidGenerator := range uidGenerators {
+		uid, err := uidGenerator.GetUID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uid <= lasts[i] || timestampOf(uid) < timestampOf(lasts[i]) {
+			t.Errorf("uid %d at %d is not after %d at %d", uid, timestampOf(uid), lasts[i], timestampOf(lasts[i]))
+		}
+		lasts[i] = uid
+	}
+	// the ring buffer is padded again after the step
+	for n := 0; n < 2*cachedUidGenerator.ringBuffer.bufferSize; n++ {
+		uid, err := cachedUidGenerator.GetUID()
+		for err != nil {
+			uid, err = cachedUidGenerator.GetUID()
+		}
+		if uid <= lasts[1] || timestampOf(uid) < timestampOf(lasts[1]) {
+			t.Fatalf("cached uid %d at %d is not after %d at %d", uid, timestampOf(uid), lasts[1], timestampOf(lasts[1]))
+		}
+		lasts[1] = uid
+	}
+}
@@ -3,13 +3,15 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"time"
 	"uidgenerator"
 )
 
 func main() {
 
-	workerIdAssigner, err := uidgenerator.NewDisposableWorkerIdAssigner("root:syr1120@xys.com@tcp(127.0.0.1:3306)/uid_generator?charset=utf8mb4&parseTime=true&loc=Local")
+	workerIdAssigner, err := uidgenerator.NewSQLiteWorkerIdAssigner(filepath.Join(os.TempDir(), "uid_generator.db"))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -1,9 +1,13 @@
 package uidgenerator
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -30,26 +34,106 @@ You can also specify the bits by custom setting.
 timeBits: default as 28
 workerBits: default as 22
 seqBits: default as 13
-epochStr: Epoch date string format 'yyyy-MM-dd'. Default as '2016-05-20'
+epoch: Epoch date string format 'yyyy-MM-dd' in UTC, 'yyyy-MM-ddZ07:00' or RFC3339, or a time.Time by WithEpochTime. Default as '2023-05-20'
+datacenterBits: Split from workerBits as Twitter's Snowflake, the worker id is assigned within the datacenter. Default as 0
+
+	+------+----------------------+---------------+------------+-----------+
+	| sign |     delta seconds    | datacenter id | machine id | sequence  |
+	+------+----------------------+---------------+------------+-----------+
+	  1bit          28bits              5bits         17bits       13bits
+
+timeUnit: Unit of the timestamp, TimeUnitMillisecond or TimeUnit10Millisecond for more uid in a second. Default as TimeUnitSecond
+The timestamp lasts (1 << timeBits) units and the sequence is reset every unit, such as Twitter's Snowflake:
+
+	+------+----------------------+----------------+-----------+
+	| sign |  delta milliseconds  | worker node id | sequence  |
+	+------+----------------------+----------------+-----------+
+	  1bit          41bits              10bits         12bits
+
+rollover: A second epoch taking over from its time on, the version bit above the timestamp is increased from then on,
+so the uid keep increasing across the switch. The bit is reserved from the 63 bits, such as WithBits(27, 22, 13):
+
+	+------+---------+----------------------+----------------+-----------+
+	| sign | version |     delta seconds    | worker node id | sequence  |
+	+------+---------+----------------------+----------------+-----------+
+	  1bit    1bit           27bits              22bits         13bits
+
+layout: Any Layout instead of the bits above, the fixed segments such as a shard are set by WithSegmentValue
 
 The total bits must be 64 -1
 */
+
+const (
+	defaultTimeBits   = 28
+	defaultWorkerBits = 22
+	defaultSeqBits    = 13
+	// defaultLastTimestampSaveInterval Interval to save the last timestamp to LastTimestampKeeper
+	defaultLastTimestampSaveInterval = 3 * time.Second
+	// defaultExhaustionWarningThreshold Warn 180 and 90 days before the timestamp bits run out by default
+	defaultExhaustionWarningThreshold = 90 * 24 * time.Hour
+)
+
 type DefaultUidGenerator struct {
 	// Bits allocate
 	timeBits   int
 	workerBits int
 	seqBits    int
-	// Customer epoch, unit as second. For example 2016-05-20 (ms: 1463673600000)
-	epochStr     string
-	epochSeconds int64
+	// Datacenter bits split from workerBits, and the datacenter id in them
+	datacenterBits int
+	datacenterId   int64
+	// Layout of the uid, built from the bits above if not set
+	layout        *Layout
+	segmentValues map[string]int64
+	// Unit of the timestamp
+	timeUnit TimeUnit
+	// Source of the current time
+	clock Clock
+	// Customer epoch, parsed from epochStr unless set by WithEpochTime. For example 2016-05-20 (ms: 1463673600000)
+	epochStr string
+	epoch    time.Time
+	// epochTimestamp The customer epoch in timeUnit
+	epochTimestamp int64
+	// The second epoch taking over from its time on, with the version increased by rolloverVersionBits
+	rollover            time.Time
+	rolloverTimestamp   int64
+	rolloverVersionBits int64
+	// Called once the time left before the timestamp bits run out is less than each threshold
+	exhaustionWarningHandler    func(threshold time.Duration, exhaustion time.Time)
+	exhaustionWarningThresholds []time.Duration
+	exhaustionWarningTimers     []*time.Timer
 	// Stable fields after DefaultUidGenerator initializing
 	bitsAllocator *bitsAllocator
 	workerId      int64
+	workerLease   WorkerLease
+	// Called once the worker lease is lost, GetUID is refused from then on
+	workerLeaseLostHandler func(err error)
+	// Deal with the clock behind the last timestamp up to maxClockDrift
+	clockBackwardsStrategy  ClockBackwardsStrategy
+	maxClockDrift           time.Duration
+	backupWorkerIdAssigners []WorkerIdAssigner
+	// workers The primary worker at 0 and the backups for ClockBackwardsBackupWorker
+	workers []*backupWorker
 	// Volatile fields caused by nextId()
-	sequence   int64
-	lastSecond int64
+	mutex sync.Mutex
+	// The worker generating uid, it's the primary one unless switched by ClockBackwardsBackupWorker
+	activeWorker        int
+	activeWorkerId      int64
+	clockBackwardsStats ClockBackwardsStats
+	sequence            int64
+	// lastTimestamp The last timestamp in timeUnit
+	lastTimestamp int64
+	// The last timestamp borrowed by CachedUidGenerator, nil for DefaultUidGenerator
+	bufferedLastTimestamp *paddedAtomicLong
 
 	workerIdAssigner WorkerIdAssigner
+	// Keep the last timestamp if workerIdAssigner is a LastTimestampKeeper
+	lastTimestampSaveInterval time.Duration
+	lastTimestampWait         time.Duration
+	stopSaving                chan struct{}
+	savingDone                chan struct{}
+	shutdown                  chan struct{}
+	shutdownOnce              sync.Once
+	shutdownErr               error
 }
 
 type OptionDefault func(defaultUidGenerator *DefaultUidGenerator)
@@ -62,103 +146,761 @@ func WithBits(timeBits, workerBits, seqBits int) OptionDefault {
 	}
 }
 
+// WithDatacenter Split datacenterBits from the worker bits for datacenterId, such as 2 bits for 3 regions.
+// The worker id is assigned within the datacenter, it has the rest of the worker bits
+func WithDatacenter(datacenterBits int, datacenterId int64) OptionDefault {
+	return func(defaultUidGenerator *DefaultUidGenerator) {
+		defaultUidGenerator.datacenterBits = datacenterBits
+		defaultUidGenerator.datacenterId = datacenterId
+	}
+}
+
+// WithLayout Generate the uid of layout, WithBits and the datacenter bits of WithDatacenter are ignored
+func WithLayout(layout *Layout) OptionDefault {
+	return func(defaultUidGenerator *DefaultUidGenerator) {
+		defaultUidGenerator.layout = layout
+	}
+}
+
+// WithSegmentValue The value of a fixed segment of the layout, such as the shard, default as 0
+func WithSegmentValue(name string, value int64) OptionDefault {
+	return func(defaultUidGenerator *DefaultUidGenerator) {
+		if defaultUidGenerator.segmentValues == nil {
+			defaultUidGenerator.segmentValues = make(map[string]int64)
+		}
+		defaultUidGenerator.segmentValues[name] = value
+	}
+}
+
+// WithTimeUnit Unit of the timestamp, one of TimeUnitMillisecond, TimeUnit10Millisecond and TimeUnitSecond, default as TimeUnitSecond.
+// Set the bits by WithBits accordingly, 28 bits last about 8.5 years in seconds but only 3 days in milliseconds
+func WithTimeUnit(timeUnit TimeUnit) OptionDefault {
+	return func(defaultUidGenerator *DefaultUidGenerator) {
+		defaultUidGenerator.timeUnit = timeUnit
+	}
+}
+
+// WithClock The source of the current time for generating and padding uid, default as the system clock
+func WithClock(clock Clock) OptionDefault {
+	return func(defaultUidGenerator *DefaultUidGenerator) {
+		defaultUidGenerator.clock = clock
+	}
+}
+
+// WithMonotonicClock Use a MonotonicClock anchored now, the wall clock stepping backwards doesn't reach the generator.
+// It's used by CachedUidGenerator created from the generator too
+func WithMonotonicClock(maxDrift time.Duration) OptionDefault {
+	return func(defaultUidGenerator *DefaultUidGenerator) {
+		defaultUidGenerator.clock = NewMonotonicClock(maxDrift)
+	}
+}
+
+// WithEpoch Epoch date string such as 2016-05-20 in UTC, 2016-05-20+08:00 or 2016-05-20T00:00:00+08:00
 func WithEpoch(epochStr string) OptionDefault {
 	return func(defaultUidGenerator *DefaultUidGenerator) {
 		defaultUidGenerator.epochStr = epochStr
+		defaultUidGenerator.epoch = time.Time{}
+	}
+}
+
+// WithEpochTime The epoch, it must not be in the future
+func WithEpochTime(epoch time.Time) OptionDefault {
+	return func(defaultUidGenerator *DefaultUidGenerator) {
+		defaultUidGenerator.epoch = epoch
+	}
+}
+
+// WithEpochRollover Switch to the second epoch rollover from its time on, with the version segment increased.
+// Without WithLayout, a 1 bit version is added above the timestamp, so the other bits must total 62
+func WithEpochRollover(rollover time.Time) OptionDefault {
+	return func(defaultUidGenerator *DefaultUidGenerator) {
+		defaultUidGenerator.rollover = rollover
+	}
+}
+
+// WithExhaustionWarning Call handler once the time left before the timestamp bits run out is less than each threshold,
+// such as 180 and 90 days, which are the defaults without thresholds
+func WithExhaustionWarning(handler func(threshold time.Duration, exhaustion time.Time), thresholds ...time.Duration) OptionDefault {
+	return func(defaultUidGenerator *DefaultUidGenerator) {
+		if len(thresholds) == 0 {
+			thresholds = []time.Duration{defaultExhaustionWarningThreshold * 2, defaultExhaustionWarningThreshold}
+		}
+		defaultUidGenerator.exhaustionWarningHandler = handler
+		defaultUidGenerator.exhaustionWarningThresholds = thresholds
+	}
+}
+
+// WithClockBackwardsStrategy Deal with the clock behind the last timestamp up to maxDrift, default as ClockBackwardsFailFast.
+// ClockBackwardsBackupWorker needs the backup worker ids of WithBackupWorkerIdAssigners
+func WithClockBackwardsStrategy(strategy ClockBackwardsStrategy, maxDrift time.Duration) OptionDefault {
+	return func(defaultUidGenerator *DefaultUidGenerator) {
+		defaultUidGenerator.clockBackwardsStrategy = strategy
+		defaultUidGenerator.maxClockDrift = maxDrift
+	}
+}
+
+// WithBackupWorkerIdAssigners Assign a backup worker id from each assigner for ClockBackwardsBackupWorker, they are released by Shutdown
+func WithBackupWorkerIdAssigners(assigners ...WorkerIdAssigner) OptionDefault {
+	return func(defaultUidGenerator *DefaultUidGenerator) {
+		defaultUidGenerator.backupWorkerIdAssigners = assigners
+	}
+}
+
+// WithLastTimestampSaveInterval Interval to save the last timestamp to the LastTimestampKeeper, default as 3s
+func WithLastTimestampSaveInterval(interval time.Duration) OptionDefault {
+	return func(defaultUidGenerator *DefaultUidGenerator) {
+		defaultUidGenerator.lastTimestampSaveInterval = interval
+	}
+}
+
+// WithLastTimestampWait Wait up to maxWait in the constructor for the clock to pass the last timestamp
+// of the worker id, default as 0, the generator refuses to generate uid until the clock passes it
+func WithLastTimestampWait(maxWait time.Duration) OptionDefault {
+	return func(defaultUidGenerator *DefaultUidGenerator) {
+		defaultUidGenerator.lastTimestampWait = maxWait
+	}
+}
+
+// WithWorkerLeaseLostHandler Called with the cause once the worker lease is lost, but not by Shutdown.
+// Both DefaultUidGenerator and CachedUidGenerator refuse GetUID from then on, it's a chance to alert or restart
+func WithWorkerLeaseLostHandler(handler func(err error)) OptionDefault {
+	return func(defaultUidGenerator *DefaultUidGenerator) {
+		defaultUidGenerator.workerLeaseLostHandler = handler
 	}
 }
 
 func NewDefaultUidGenerator(workerIdAssigner WorkerIdAssigner, opts ...OptionDefault) (*DefaultUidGenerator, error) {
+	return NewDefaultUidGeneratorContext(context.Background(), workerIdAssigner, opts...)
+}
+
+// NewDefaultUidGeneratorContext Like NewDefaultUidGenerator, ctx is passed to the WorkerIdAssigner while assigning worker id
+func NewDefaultUidGeneratorContext(ctx context.Context, workerIdAssigner WorkerIdAssigner, opts ...OptionDefault) (*DefaultUidGenerator, error) {
 	uidGenerator := DefaultUidGenerator{
-		timeBits:   28,
-		workerBits: 22,
-		seqBits:    13,
-		// Customer epoch, unit as second. For example 2023-05-20 (s: 1684540800) util 2031-11-21
+		timeBits:   defaultTimeBits,
+		workerBits: defaultWorkerBits,
+		seqBits:    defaultSeqBits,
+		timeUnit:   TimeUnitSecond,
+		clock:      systemClock{},
+		// Customer epoch. For example 2023-05-20 (s: 1684540800) util 2031-11-21
 		epochStr:         "2023-05-20",
-		epochSeconds:     1684540800,
 		sequence:         0,
-		lastSecond:       -1,
+		lastTimestamp:    -1,
 		workerIdAssigner: workerIdAssigner,
+
+		lastTimestampSaveInterval: defaultLastTimestampSaveInterval,
+		shutdown:                  make(chan struct{}),
 	}
 	for _, opt := range opts {
 		opt(&uidGenerator)
 	}
 
+	if err := uidGenerator.timeUnit.validate(); err != nil {
+		return nil, err
+	}
+	if uidGenerator.epoch.IsZero() {
+		epoch, err := parseEpoch(uidGenerator.epochStr)
+		if err != nil {
+			return nil, err
+		}
+		uidGenerator.epoch = epoch
+	}
+	if uidGenerator.clock == nil {
+		return nil, errors.New("clock is not allowed nil")
+	}
+	if uidGenerator.epoch.After(uidGenerator.clock.Now()) {
+		return nil, fmt.Errorf("epoch %s is in the future", uidGenerator.epoch.Format(time.RFC3339))
+	}
+	uidGenerator.epochTimestamp = uidGenerator.timeUnit.timestamp(uidGenerator.epoch)
 	// initialize bits allocator
-	bitsAllocator, err := newBitsAllocator(uidGenerator.timeBits, uidGenerator.workerBits, uidGenerator.seqBits)
+	if uidGenerator.layout == nil {
+		if uidGenerator.datacenterBits < 0 || uidGenerator.datacenterBits >= uidGenerator.workerBits {
+			return nil, fmt.Errorf("datacenter bits %d must be in [0, worker bits %d)", uidGenerator.datacenterBits, uidGenerator.workerBits)
+		}
+		versionBits := 0
+		if !uidGenerator.rollover.IsZero() {
+			versionBits = 1
+		}
+		layout, err := defaultLayout(versionBits, uidGenerator.timeBits, uidGenerator.datacenterBits, uidGenerator.workerBits-uidGenerator.datacenterBits, uidGenerator.seqBits)
+		if err != nil {
+			return nil, err
+		}
+		uidGenerator.layout = layout
+	}
+	_, datacenter := uidGenerator.layout.Segment(SegmentDatacenterId)
+	segmentValues := make(map[string]int64, len(uidGenerator.segmentValues)+1)
+	for name, value := range uidGenerator.segmentValues {
+		segmentValues[name] = value
+	}
+	if datacenter {
+		segmentValues[SegmentDatacenterId] = uidGenerator.datacenterId
+	} else if uidGenerator.datacenterId != 0 {
+		return nil, errors.New("datacenter id is set, but there is no datacenter segment")
+	}
+	bitsAllocator, err := newBitsAllocator(uidGenerator.layout, segmentValues)
 	if err != nil {
 		return nil, err
 	}
 	uidGenerator.bitsAllocator = bitsAllocator
+	if !uidGenerator.rollover.IsZero() {
+		if err := uidGenerator.initRollover(segmentValues[SegmentVersion]); err != nil {
+			return nil, err
+		}
+	}
+	exhaustion := uidGenerator.ExhaustionTime()
+	if !uidGenerator.clock.Now().Before(exhaustion) {
+		return nil, fmt.Errorf("timestamp bits is exhausted since %s", exhaustion.Format(time.RFC3339))
+	}
+	switch uidGenerator.clockBackwardsStrategy {
+	case ClockBackwardsFailFast:
+	case ClockBackwardsWait, ClockBackwardsBorrow, ClockBackwardsBackupWorker:
+		if uidGenerator.maxClockDrift <= 0 {
+			return nil, fmt.Errorf("max clock drift of %v must be positive", uidGenerator.clockBackwardsStrategy)
+		}
+		if uidGenerator.clockBackwardsStrategy == ClockBackwardsBackupWorker && len(uidGenerator.backupWorkerIdAssigners) == 0 {
+			return nil, errors.New("backup worker id assigners are required by ClockBackwardsBackupWorker")
+		}
+	default:
+		return nil, fmt.Errorf("unknown clock backwards strategy %v", uidGenerator.clockBackwardsStrategy)
+	}
 	// initialize worker id
 	if uidGenerator.workerIdAssigner == nil {
 		return nil, errors.New("workerIdAssigner is not allowed nil")
 	}
 	uidGenerator.workerIdAssigner = workerIdAssigner
-	workerId, err := uidGenerator.workerIdAssigner.assignWorkerId()
+	if boundedAssigner, ok := workerIdAssigner.(BoundedWorkerIdAssigner); ok {
+		boundedAssigner.SetWorkerIdBounds(WorkerIdBounds{
+			MaxWorkerId:  bitsAllocator.MaxWorkerId,
+			Datacenter:   datacenter,
+			DatacenterId: uidGenerator.datacenterId,
+		})
+	}
+	workerLease, err := uidGenerator.workerIdAssigner.AssignWorkerId(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if workerId > bitsAllocator.MaxWorkerId {
+	workerId := workerLease.WorkerId()
+	if workerId < 0 || workerId > bitsAllocator.MaxWorkerId {
+		_ = uidGenerator.workerIdAssigner.Release(ctx)
 		return nil, fmt.Errorf("worker id %d exceeds the max %d", workerId, bitsAllocator.MaxWorkerId)
 	}
 	uidGenerator.workerId = workerId
+	uidGenerator.activeWorkerId = workerId
+	uidGenerator.workerLease = workerLease
+	if workerLease.Done() != nil && uidGenerator.workerLeaseLostHandler != nil {
+		go uidGenerator.watchWorkerLease(workerLease)
+	}
+	// initialize backup worker ids
+	if uidGenerator.clockBackwardsStrategy == ClockBackwardsBackupWorker {
+		if err := uidGenerator.assignBackupWorkers(ctx); err != nil {
+			_ = uidGenerator.releaseBackupWorkers(ctx)
+			_ = uidGenerator.workerIdAssigner.Release(ctx)
+			return nil, err
+		}
+	}
+	// initialize last timestamp
+	keeper, ok := workerIdAssigner.(LastTimestampKeeper)
+	if ok {
+		if err := uidGenerator.restoreLastTimestamp(ctx, keeper); err != nil {
+			_ = uidGenerator.releaseBackupWorkers(ctx)
+			_ = uidGenerator.workerIdAssigner.Release(ctx)
+			return nil, err
+		}
+	}
+	if (ok || uidGenerator.hasBackupKeeper()) && uidGenerator.lastTimestampSaveInterval > 0 {
+		uidGenerator.stopSaving = make(chan struct{})
+		uidGenerator.savingDone = make(chan struct{})
+		go uidGenerator.keepLastTimestamp()
+	}
+	uidGenerator.watchExhaustion()
 	return &uidGenerator, nil
 }
 
+// assignBackupWorkers Assign the backup worker ids, restored with their last timestamps like the primary one
+func (d *DefaultUidGenerator) assignBackupWorkers(ctx context.Context) error {
+	d.workers = []*backupWorker{{assigner: d.workerIdAssigner, workerId: d.workerId, lease: d.workerLease}}
+	for _, assigner := range d.backupWorkerIdAssigners {
+		if boundedAssigner, ok := assigner.(BoundedWorkerIdAssigner); ok {
+			boundedAssigner.SetWorkerIdBounds(WorkerIdBounds{
+				MaxWorkerId:  d.bitsAllocator.MaxWorkerId,
+				Datacenter:   d.bitsAllocator.MaxDatacenterId > 0,
+				DatacenterId: d.datacenterId,
+			})
+		}
+		lease, err := assigner.AssignWorkerId(ctx)
+		if err != nil {
+			return err
+		}
+		worker := backupWorker{assigner: assigner, workerId: lease.WorkerId(), lease: lease, lastTimestamp: -1}
+		d.workers = append(d.workers, &worker)
+		if worker.workerId < 0 || worker.workerId > d.bitsAllocator.MaxWorkerId {
+			return fmt.Errorf("backup worker id %d exceeds the max %d", worker.workerId, d.bitsAllocator.MaxWorkerId)
+		}
+		if lease.Done() != nil && d.workerLeaseLostHandler != nil {
+			go d.watchWorkerLease(lease)
+		}
+		if keeper, ok := assigner.(LastTimestampKeeper); ok {
+			lastTimestamp, err := keeper.LoadLastTimestamp(ctx, worker.workerId)
+			if err != nil {
+				return err
+			}
+			if !lastTimestamp.IsZero() {
+				worker.lastTimestamp = d.timeUnit.timestamp(lastTimestamp)
+				worker.sequence = d.bitsAllocator.MaxSequence
+			}
+		}
+	}
+	return nil
+}
+
+// hasBackupKeeper Whether any backup worker id keeps its last timestamp
+func (d *DefaultUidGenerator) hasBackupKeeper() bool {
+	for i := 1; i < len(d.workers); i++ {
+		if _, ok := d.workers[i].assigner.(LastTimestampKeeper); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// saveBackupTimestamps Save the last timestamp of each backup worker id to its own keeper
+func (d *DefaultUidGenerator) saveBackupTimestamps(ctx context.Context) {
+	d.mutex.Lock()
+	if len(d.workers) > 0 {
+		d.workers[d.activeWorker].lastTimestamp = d.lastTimestamp
+	}
+	workers := d.workers
+	lastTimestamps := make([]int64, len(workers))
+	for i, worker := range workers {
+		lastTimestamps[i] = worker.lastTimestamp
+	}
+	d.mutex.Unlock()
+	for i := 1; i < len(workers); i++ {
+		if keeper, ok := workers[i].assigner.(LastTimestampKeeper); ok && lastTimestamps[i] >= 0 {
+			if err := keeper.SaveLastTimestamp(ctx, workers[i].workerId, d.timeUnit.time(lastTimestamps[i])); err != nil {
+				log.Printf("failed to save the last timestamp of backup worker id %d: %v", workers[i].workerId, err)
+			}
+		}
+	}
+}
+
+// releaseBackupWorkers Save the last timestamps of the backup worker ids and release them, the first error is returned
+func (d *DefaultUidGenerator) releaseBackupWorkers(ctx context.Context) error {
+	d.saveBackupTimestamps(ctx)
+	d.mutex.Lock()
+	workers := d.workers
+	d.mutex.Unlock()
+	var firstErr error
+	for i := 1; i < len(workers); i++ {
+		if err := workers[i].assigner.Release(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// initRollover Check the rollover epoch against the layout, version is the value of the version segment before the rollover
+func (d *DefaultUidGenerator) initRollover(version int64) error {
+	versionSegment, ok := d.layout.Segment(SegmentVersion)
+	if !ok {
+		return errors.New("epoch rollover needs the version segment")
+	}
+	if versionSegment.Shift < d.bitsAllocator.TimestampShift {
+		return errors.New("segment version must be above the timestamp, or the uid decrease after the rollover")
+	}
+	if version+1 > versionSegment.Max {
+		return fmt.Errorf("version %d is the max, no version left for the rollover", version)
+	}
+	if !d.rollover.After(d.epoch) {
+		return fmt.Errorf("rollover %s must be after the epoch %s", d.rollover.Format(time.RFC3339), d.epoch.Format(time.RFC3339))
+	}
+	d.rolloverTimestamp = d.timeUnit.timestamp(d.rollover)
+	if exhaustion := d.timeUnit.time(d.epochTimestamp + d.bitsAllocator.MaxDeltaTimestamp + 1); d.rollover.After(exhaustion) {
+		return fmt.Errorf("rollover %s must be before the timestamp bits run out at %s", d.rollover.Format(time.RFC3339), exhaustion.Format(time.RFC3339))
+	}
+	d.rolloverVersionBits = versionSegment.Allocate(1)
+	return nil
+}
+
+// epochOf The epoch of timestamp and the bits to add to the version segment, the rollover epoch takes over from its time on
+func (d *DefaultUidGenerator) epochOf(timestamp int64) (epochTimestamp, versionBits int64) {
+	if d.rolloverVersionBits != 0 && timestamp >= d.rolloverTimestamp {
+		return d.rolloverTimestamp, d.rolloverVersionBits
+	}
+	return d.epochTimestamp, 0
+}
+
+// watchExhaustion Call exhaustionWarningHandler at each threshold before ExhaustionTime, until Shutdown.
+// The thresholds passed already are reported at once by the smallest of them
+func (d *DefaultUidGenerator) watchExhaustion() {
+	if d.exhaustionWarningHandler == nil {
+		return
+	}
+	exhaustion := d.ExhaustionTime()
+	passed := time.Duration(-1)
+	for _, threshold := range d.exhaustionWarningThresholds {
+		threshold := threshold
+		wait := exhaustion.Add(-threshold).Sub(d.clock.Now())
+		if wait <= 0 {
+			if passed < 0 || threshold < passed {
+				passed = threshold
+			}
+			continue
+		}
+		d.exhaustionWarningTimers = append(d.exhaustionWarningTimers, time.AfterFunc(wait, func() {
+			d.exhaustionWarningHandler(threshold, exhaustion)
+		}))
+	}
+	if passed >= 0 {
+		d.exhaustionWarningHandler(passed, exhaustion)
+	}
+}
+
+// parseEpoch Parse the epoch date string, the date without timezone is in UTC
+func parseEpoch(epochStr string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006-01-02Z07:00", time.RFC3339} {
+		if epoch, err := time.Parse(layout, epochStr); err == nil {
+			return epoch, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("epoch %q must be yyyy-MM-dd, yyyy-MM-ddZ07:00 or RFC3339", epochStr)
+}
+
+// ExhaustionTime The time the timestamp bits run out, no uid can be generated from then on. It's of the rollover epoch if any
+func (d *DefaultUidGenerator) ExhaustionTime() time.Time {
+	if d.rolloverVersionBits != 0 {
+		return d.timeUnit.time(d.rolloverTimestamp + d.bitsAllocator.MaxDeltaTimestamp + 1).In(d.rollover.Location())
+	}
+	return d.timeUnit.time(d.epochTimestamp + d.bitsAllocator.MaxDeltaTimestamp + 1).In(d.epoch.Location())
+}
+
+// Shutdown Save the last timestamp and release the worker id, the generator must not be used after shutdown.
+// It's done once, the later calls return the result of the first one
+func (d *DefaultUidGenerator) Shutdown(ctx context.Context) error {
+	d.shutdownOnce.Do(func() {
+		d.shutdownErr = d.doShutdown(ctx)
+	})
+	return d.shutdownErr
+}
+
+func (d *DefaultUidGenerator) doShutdown(ctx context.Context) error {
+	close(d.shutdown)
+	for _, timer := range d.exhaustionWarningTimers {
+		timer.Stop()
+	}
+	if d.stopSaving != nil {
+		close(d.stopSaving)
+		<-d.savingDone
+	}
+	if keeper, ok := d.workerIdAssigner.(LastTimestampKeeper); ok {
+		if err := d.saveLastTimestamp(ctx, keeper); err != nil {
+			log.Printf("failed to save the last timestamp of worker id %d: %v", d.workerId, err)
+		}
+	}
+	backupErr := d.releaseBackupWorkers(ctx)
+	if err := d.workerIdAssigner.Release(ctx); err != nil {
+		return err
+	}
+	return backupErr
+}
+
+// ClockBackwardsStats Metrics of the clock behind the last timestamp since the generator is created
+func (d *DefaultUidGenerator) ClockBackwardsStats() ClockBackwardsStats {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.clockBackwardsStats
+}
+
+// watchWorkerLease Call workerLeaseLostHandler once the lease of the primary or a backup worker is lost, unless it's released by Shutdown
+func (d *DefaultUidGenerator) watchWorkerLease(workerLease WorkerLease) {
+	select {
+	case <-workerLease.Done():
+	case <-d.shutdown:
+		return
+	}
+	select {
+	case <-d.shutdown:
+	default:
+		d.workerLeaseLostHandler(workerLeaseErr(workerLease))
+	}
+}
+
+/*
+restoreLastTimestamp
+The worker id may be used by another node before, the uid must not be generated at or before the last timestamp it used.
+The last timestamp is restored with the max sequence, so the generator waits for the next unit
+even if the clock is at the last timestamp now.
+*/
+func (d *DefaultUidGenerator) restoreLastTimestamp(ctx context.Context, keeper LastTimestampKeeper) error {
+	lastTimestamp, err := keeper.LoadLastTimestamp(ctx, d.workerId)
+	if err != nil {
+		return err
+	}
+	if lastTimestamp.IsZero() {
+		return nil
+	}
+	d.lastTimestamp = d.timeUnit.timestamp(lastTimestamp)
+	d.sequence = d.bitsAllocator.MaxSequence
+	if wait := d.timeUnit.time(d.lastTimestamp + 1).Sub(d.clock.Now()); wait > 0 {
+		if wait > d.lastTimestampWait {
+			log.Printf("worker id %d was used until %s, refusing UID generate for %v", d.workerId, lastTimestamp.Format(time.RFC3339), wait)
+			return nil
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// keepLastTimestamp Save the last timestamps of the primary and backup worker ids every lastTimestampSaveInterval until Shutdown
+func (d *DefaultUidGenerator) keepLastTimestamp() {
+	keeper, ok := d.workerIdAssigner.(LastTimestampKeeper)
+	defer close(d.savingDone)
+	ticker := time.NewTicker(d.lastTimestampSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stopSaving:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), d.lastTimestampSaveInterval)
+		if ok {
+			if err := d.saveLastTimestamp(ctx, keeper); err != nil {
+				log.Printf("failed to save the last timestamp of worker id %d: %v", d.workerId, err)
+			}
+		}
+		d.saveBackupTimestamps(ctx)
+		cancel()
+	}
+}
+
+func (d *DefaultUidGenerator) saveLastTimestamp(ctx context.Context, keeper LastTimestampKeeper) error {
+	lastTimestamp := d.highWaterTimestamp()
+	if lastTimestamp < 0 {
+		return nil
+	}
+	return keeper.SaveLastTimestamp(ctx, d.workerId, d.timeUnit.time(lastTimestamp))
+}
+
+// highWaterTimestamp The last timestamp used, including the ones borrowed from the future by CachedUidGenerator.
+// The ones of the backup workers are included too, the primary worker may be switched from
+func (d *DefaultUidGenerator) highWaterTimestamp() int64 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	lastTimestamp := d.lastTimestamp
+	for _, worker := range d.workers {
+		if worker.lastTimestamp > lastTimestamp {
+			lastTimestamp = worker.lastTimestamp
+		}
+	}
+	if d.bufferedLastTimestamp != nil && d.bufferedLastTimestamp.Load() > lastTimestamp {
+		lastTimestamp = d.bufferedLastTimestamp.Load()
+	}
+	return lastTimestamp
+}
+
 func (d *DefaultUidGenerator) GetUID() (int64, error) {
 	return d.nextId()
 }
 
+/*
+ParseUID
+Parse the segments of the layout in order, the timestamp is formatted.
+With a datacenter segment, workerId is the whole worker field datacenterId * (max machine id + 1) + machineId,
+which is the row id of LeaseWorkerIdAssigner scoped by datacenter.
+*/
 func (d *DefaultUidGenerator) ParseUID(uid int64) string {
-	totalBits := totalBits
-	signBits := d.bitsAllocator.SignBits
-	timestampBits := d.bitsAllocator.TimestampBits
-	workerIdBits := d.bitsAllocator.WorkerIdBits
-	sequenceBits := d.bitsAllocator.SequenceBits
-	// parse UID
-	sequence := uint64(uid<<(totalBits-sequenceBits)) >> (totalBits - sequenceBits)
-	workerId := uint64(uid<<(timestampBits+signBits)) >> (totalBits - workerIdBits)
-	deltaSeconds := uint64(uid) >> (workerIdBits + sequenceBits)
-	thatTime := time.UnixMilli((d.epochSeconds + int64(deltaSeconds)) * 1000)
-	thatTimeStr := thatTime.Format("2006-01-02 15:04:05")
-	return fmt.Sprintf("{\"uid\":\"%d\",\"binary\":\"%064s\",\"timestamp\":\"%s\",\"workerId\":\"%d\",\"sequence\":\"%d\"}", uid, strconv.FormatInt(uid, 2), thatTimeStr, workerId, sequence)
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, "{\"uid\":\"%d\",\"binary\":\"%064s\"", uid, strconv.FormatInt(uid, 2))
+	datacenter, hasDatacenter := d.bitsAllocator.layout.Segment(SegmentDatacenterId)
+	epochTimestamp := d.epochTimestamp
+	if version, ok := d.bitsAllocator.layout.Segment(SegmentVersion); ok && d.rolloverVersionBits != 0 &&
+		version.Extract(uid) > version.Extract(d.bitsAllocator.fixed) {
+		epochTimestamp = d.rolloverTimestamp
+	}
+	for _, segment := range d.bitsAllocator.layout.Segments() {
+		value := segment.Extract(uid)
+		switch {
+		case segment.Name == SegmentTimestamp:
+			thatTime := d.timeUnit.time(epochTimestamp + value)
+			fmt.Fprintf(&builder, ",\"timestamp\":\"%s\"", thatTime.Format(d.timeUnit.layout()))
+		case segment.Name == SegmentWorkerId && hasDatacenter:
+			workerId := datacenter.Extract(uid)*(segment.Max+1) + value
+			fmt.Fprintf(&builder, ",\"workerId\":\"%d\",\"machineId\":\"%d\"", workerId, value)
+		default:
+			fmt.Fprintf(&builder, ",%q:\"%d\"", segment.Name, value)
+		}
+	}
+	builder.WriteString("}")
+	return builder.String()
 }
 
 func (d *DefaultUidGenerator) nextId() (int64, error) {
-	currentSecond := time.Now().Unix()
-	// Clock moved backwards, refuse to generate uid
-	if currentSecond < d.lastSecond {
-		refusedSeconds := d.lastSecond - currentSecond
-		return 0, fmt.Errorf("clock moved backwards. Refusing for %d seconds", refusedSeconds)
-	}
-	// At the same second, increase sequence
-	if currentSecond == d.lastSecond {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if err := d.checkWorkerLease(); err != nil {
+		return 0, err
+	}
+	currentTimestamp, err := d.getCurrentTimestamp()
+	if err != nil {
+		return 0, err
+	}
+	// Clock moved backwards, deal with it by the strategy
+	behind := currentTimestamp < d.lastTimestamp
+	if behind {
+		currentTimestamp, err = d.clockMovedBackwards(currentTimestamp)
+		if err != nil {
+			return 0, err
+		}
+	}
+	// At the same timestamp, increase sequence
+	if currentTimestamp == d.lastTimestamp {
 		d.sequence = (d.sequence + 1) & d.bitsAllocator.MaxSequence
-		// Exceed the max sequence, we wait the next second to generate uid
+		// Exceed the max sequence, we wait the next timestamp to generate uid
 		if d.sequence == 0 {
-			var err error
-			currentSecond, err = d.getNextSecond(d.lastSecond)
+			currentTimestamp, err = d.getNextTimestamp(d.lastTimestamp, behind && d.clockBackwardsStrategy == ClockBackwardsBorrow)
 			if err != nil {
+				// the sequence of the last timestamp is used up
+				d.sequence = d.bitsAllocator.MaxSequence
 				return 0, err
 			}
+		} else if behind && d.clockBackwardsStrategy == ClockBackwardsBorrow {
+			// the spare sequence of the last timestamp ahead of the clock
+			d.clockBackwardsStats.Borrowed++
 		}
-		// At the different second, sequence restart from zero
+		// At the different timestamp, sequence restart from zero
 	} else {
 		d.sequence = 0
 	}
-	d.lastSecond = currentSecond
+	d.lastTimestamp = currentTimestamp
 	// Allocate bits for UID
-	return d.bitsAllocator.allocate(currentSecond-d.epochSeconds, d.workerId, d.sequence), nil
+	epochTimestamp, versionBits := d.epochOf(currentTimestamp)
+	return d.bitsAllocator.allocate(currentTimestamp-epochTimestamp, d.activeWorkerId, d.sequence) + versionBits, nil
 }
 
-func (d *DefaultUidGenerator) getNextSecond(lastTimestamp int64) (int64, error) {
-	timestamp, err := d.getCurrentSecond()
+/*
+clockMovedBackwards
+The current timestamp is behind the last timestamp, returns the timestamp to generate uid at by the strategy.
+ClockBackwardsWait waits on d.clock with the mutex held, the concurrent callers are blocked up to the max drift as well,
+so that no uid is generated at the timestamps waited for.
+ClockBackwardsBorrow returns the last timestamp, the spare sequence of it is used first.
+*/
+func (d *DefaultUidGenerator) clockMovedBackwards(currentTimestamp int64) (int64, error) {
+	drift := time.Duration(d.lastTimestamp-currentTimestamp) * d.timeUnit.Duration()
+	d.clockBackwardsStats.Count++
+	if drift > d.clockBackwardsStats.MaxDrift {
+		d.clockBackwardsStats.MaxDrift = drift
+	}
+	refuse := func(drift time.Duration) (int64, error) {
+		d.clockBackwardsStats.Refused++
+		return 0, &ClockBackwardsError{Drift: drift, Strategy: d.clockBackwardsStrategy}
+	}
+	if d.clockBackwardsStrategy == ClockBackwardsFailFast || drift > d.maxClockDrift {
+		return refuse(drift)
+	}
+	switch d.clockBackwardsStrategy {
+	case ClockBackwardsWait:
+		d.clockBackwardsStats.Waited++
+		deadline := d.clock.Now().Add(d.maxClockDrift)
+		for currentTimestamp < d.lastTimestamp {
+			drift = time.Duration(d.lastTimestamp-currentTimestamp) * d.timeUnit.Duration()
+			wait := deadline.Sub(d.clock.Now())
+			if wait <= 0 {
+				return refuse(drift)
+			}
+			if drift < wait {
+				wait = drift
+			}
+			sleep(d.clock, wait)
+			var err error
+			if currentTimestamp, err = d.getCurrentTimestamp(); err != nil {
+				return 0, err
+			}
+		}
+		return currentTimestamp, nil
+	case ClockBackwardsBorrow:
+		return d.lastTimestamp, nil
+	default:
+		if !d.switchWorker(currentTimestamp) {
+			return refuse(drift)
+		}
+		d.clockBackwardsStats.Switched++
+		log.Printf("clock moved backwards %v, switched to backup worker id %d", drift, d.activeWorkerId)
+		return currentTimestamp, nil
+	}
+}
+
+// switchWorker Switch to a worker which has not used timestamp, false if there is none
+func (d *DefaultUidGenerator) switchWorker(timestamp int64) bool {
+	for i, worker := range d.workers {
+		if i == d.activeWorker || worker.lastTimestamp >= timestamp || workerLeaseErr(worker.lease) != nil {
+			continue
+		}
+		active := d.workers[d.activeWorker]
+		active.lastTimestamp, active.sequence = d.lastTimestamp, d.sequence
+		d.activeWorker = i
+		d.activeWorkerId = worker.workerId
+		d.lastTimestamp, d.sequence = worker.lastTimestamp, worker.sequence
+		return true
+	}
+	return false
+}
+
+// checkWorkerLease Refuse to generate uid once the worker lease is lost or past its deadline, another node may own the worker id now.
+// The lease of the active backup worker is checked too
+func (d *DefaultUidGenerator) checkWorkerLease() error {
+	leases := []WorkerLease{d.workerLease}
+	if d.activeWorker > 0 {
+		leases = append(leases, d.workers[d.activeWorker].lease)
+	}
+	for _, lease := range leases {
+		if err := workerLeaseErr(lease); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workerLeaseErr ErrWorkerLeaseLost once the lease is lost or past its deadline, otherwise nil
+func workerLeaseErr(workerLease WorkerLease) error {
+	select {
+	case <-workerLease.Done():
+		err := workerLease.Err()
+		if !errors.Is(err, ErrWorkerLeaseLost) {
+			err = fmt.Errorf("%w: %v", ErrWorkerLeaseLost, err)
+		}
+		return err
+	default:
+	}
+	// the renewal may be late to mark the lease lost, the deadline is checked on the wall clock
+	if deadline, ok := workerLease.Deadline(); ok && !time.Now().Before(deadline) {
+		return fmt.Errorf("%w: worker id %d: deadline %s passed", ErrWorkerLeaseLost, workerLease.WorkerId(), deadline.Format(time.RFC3339Nano))
+	}
+	return nil
+}
+
+// getNextTimestamp Wait for the timestamp after lastTimestamp, or borrow it ahead of the clock moved backwards
+func (d *DefaultUidGenerator) getNextTimestamp(lastTimestamp int64, borrow bool) (int64, error) {
+	timestamp, err := d.getCurrentTimestamp()
 	if err != nil {
 		return 0, err
 	}
+	// Borrow the next timestamp ahead of the clock instead of waiting, up to the max drift
+	if borrow && timestamp <= lastTimestamp {
+		if time.Duration(lastTimestamp+1-timestamp)*d.timeUnit.Duration() <= d.maxClockDrift {
+			d.clockBackwardsStats.Borrowed++
+			return lastTimestamp + 1, d.checkExhausted(lastTimestamp + 1)
+		}
+		if timestamp < lastTimestamp {
+			d.clockBackwardsStats.Refused++
+			return 0, &ClockBackwardsError{Drift: time.Duration(lastTimestamp-timestamp) * d.timeUnit.Duration(), Strategy: d.clockBackwardsStrategy}
+		}
+	}
 	for timestamp <= lastTimestamp {
-		timestamp, err = d.getCurrentSecond()
+		timestamp, err = d.getCurrentTimestamp()
 		if err != nil {
 			return 0, err
 		}
@@ -166,10 +908,15 @@ func (d *DefaultUidGenerator) getNextSecond(lastTimestamp int64) (int64, error)
 	return timestamp, nil
 }
 
-func (d *DefaultUidGenerator) getCurrentSecond() (int64, error) {
-	currentSecond := time.Now().Unix()
-	if currentSecond-d.epochSeconds > d.bitsAllocator.MaxDeltaSeconds {
-		return 0, fmt.Errorf("timestamp bits is exhausted. Refusing UID generate. Now: %d", currentSecond)
+func (d *DefaultUidGenerator) getCurrentTimestamp() (int64, error) {
+	currentTimestamp := d.timeUnit.timestamp(d.clock.Now())
+	return currentTimestamp, d.checkExhausted(currentTimestamp)
+}
+
+// checkExhausted Refuse to generate uid at the timestamp beyond the timestamp bits
+func (d *DefaultUidGenerator) checkExhausted(timestamp int64) error {
+	if epochTimestamp, _ := d.epochOf(timestamp); timestamp-epochTimestamp > d.bitsAllocator.MaxDeltaTimestamp {
+		return fmt.Errorf("timestamp bits is exhausted. Refusing UID generate. Now: %d", timestamp)
 	}
-	return currentSecond, nil
+	return nil
 }
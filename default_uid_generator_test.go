@@ -0,0 +1,95 @@
+package uidgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDatacenter(t *testing.T) {
+	db := openSQLiteWorkerNode(t)
+	ctx := context.Background()
+	for _, datacenterId := range []int64{1, 2} {
+		assigner, err := NewLeaseWorkerIdAssigner(db, SQLiteDialect{}, WithLeaseTTL(3*time.Second), WithLeaseHeartbeatInterval(time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defaultUidGenerator, err := NewDefaultUidGenerator(assigner, WithDatacenter(2, datacenterId))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the worker ids are scoped to the datacenter, both datacenters start from 1
+		if defaultUidGenerator.workerId != 1 {
+			t.Errorf("worker id of datacenter %d is %d, want 1", datacenterId, defaultUidGenerator.workerId)
+		}
+		uid, err := defaultUidGenerator.GetUID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var parsed map[string]string
+		if err := json.Unmarshal([]byte(defaultUidGenerator.ParseUID(uid)), &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if parsed["datacenterId"] != strconv.FormatInt(datacenterId, 10) || parsed["machineId"] != "1" {
+			t.Errorf("parsed %v, want datacenter %d and machine 1", parsed, datacenterId)
+		}
+		// the worker field of the uid is the row id
+		workerId, _ := strconv.ParseInt(parsed["workerId"], 10, 64)
+		if _, err := assigner.Registry().Get(ctx, workerId); err != nil {
+			t.Errorf("worker node %d of the uid: %v", workerId, err)
+		}
+		if err := defaultUidGenerator.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithDatacenter(2, 4)); err == nil {
+		t.Error("datacenter id exceeds the max should be refused")
+	}
+}
+
+func TestEpoch(t *testing.T) {
+	// the date a year ago, the time bits never run out whenever the test runs
+	zone := time.FixedZone("", 8*3600)
+	year, month, day := time.Now().In(zone).AddDate(-1, 0, 0).Date()
+	epoch := time.Date(year, month, day, 0, 0, 0, 0, zone)
+	defaultUidGenerator, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithEpoch(epoch.Format("2006-01-02Z07:00")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !defaultUidGenerator.epoch.Equal(epoch) {
+		t.Errorf("epoch is %v, want %v", defaultUidGenerator.epoch, epoch)
+	}
+	if exhaustion := epoch.Add((1 << defaultTimeBits) * time.Second); !defaultUidGenerator.ExhaustionTime().Equal(exhaustion) {
+		t.Errorf("exhaustion time is %v, want %v", defaultUidGenerator.ExhaustionTime(), exhaustion)
+	}
+	// the custom epoch drives the generation
+	before := time.Now().Unix()
+	uid, err := defaultUidGenerator.GetUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second := uid>>defaultUidGenerator.bitsAllocator.TimestampShift + epoch.Unix(); second < before || second > time.Now().Unix() {
+		t.Errorf("uid second %d, want %d", second, before)
+	}
+
+	defaultUidGenerator, err = NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithEpochTime(epoch), WithTimeUnit(TimeUnitMillisecond), WithBits(41, 10, 12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultUidGenerator.epochTimestamp != epoch.UnixMilli() {
+		t.Errorf("epoch timestamp is %d, want %d", defaultUidGenerator.epochTimestamp, epoch.UnixMilli())
+	}
+
+	for name, opt := range map[string]OptionDefault{
+		"future":    WithEpochTime(time.Now().Add(time.Hour)),
+		"format":    WithEpoch("2020/01/01"),
+		"exhausted": WithEpochTime(time.Now().AddDate(-9, 0, 0)),
+	} {
+		if _, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, opt); err == nil {
+			t.Errorf("%s: the epoch should be refused", name)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package uidgenerator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+)
+
+/*
+Dialect
+Represents the differences between the databases storing WORKER_NODE.
+Statements of the assigners are written with '?' bind variables, the dialect rebinds them for its database.
+*/
+type Dialect interface {
+	// DriverName The database/sql driver name to open the database
+	DriverName() string
+	// Rebind Rewrite the '?' bind variables of query into the database's style
+	Rebind(query string) string
+	// InsertReturningId Execute the insert statement and return the generated value of idColumn
+	InsertReturningId(ctx context.Context, db *sql.DB, query, idColumn string, args ...any) (int64, error)
+	// Migrations Versioned steps creating and migrating the WORKER_NODE table, see EnsureSchema
+	Migrations() []Migration
+	// IsMissingObject The error is of a table or column not existing, such as the Applied query of a step not applied
+	IsMissingObject(err error) bool
+}
+
+// lastTimestampApplied The LAST_TIMESTAMP column may be added by hand before the migrations
+const lastTimestampApplied = `SELECT LAST_TIMESTAMP FROM WORKER_NODE WHERE 1 = 0`
+
+// MySQLDialect Dialect of MySQL, driven by github.com/go-sql-driver/mysql
+type MySQLDialect struct {
+}
+
+func (m MySQLDialect) DriverName() string {
+	return "mysql"
+}
+
+func (m MySQLDialect) Rebind(query string) string {
+	return query
+}
+
+func (m MySQLDialect) InsertReturningId(ctx context.Context, db *sql.DB, query, idColumn string, args ...any) (int64, error) {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// IsMissingObject Unknown column 1054 or table doesn't exist 1146
+func (m MySQLDialect) IsMissingObject(err error) bool {
+	var mysqlError *mysql.MySQLError
+	return errors.As(err, &mysqlError) && (mysqlError.Number == 1054 || mysqlError.Number == 1146)
+}
+
+func (m MySQLDialect) Migrations() []Migration {
+	return []Migration{
+		{Version: 1, Description: "create WORKER_NODE", Statements: []string{`
+CREATE TABLE IF NOT EXISTS WORKER_NODE
+(
+    ID             BIGINT      NOT NULL AUTO_INCREMENT COMMENT 'auto increment id',
+    HOST_NAME      VARCHAR(64) NOT NULL COMMENT 'host name',
+    PORT           VARCHAR(64) NOT NULL COMMENT 'port',
+    TYPE           INT         NOT NULL COMMENT 'node type: ACTUAL or CONTAINER',
+    LAUNCH_DATE    DATE        NOT NULL COMMENT 'launch date',
+    MODIFIED       TIMESTAMP   NOT NULL COMMENT 'modified time',
+    CREATED        TIMESTAMP   NOT NULL COMMENT 'created time',
+    PRIMARY KEY (ID)
+) COMMENT = 'DB WorkerID Assigner for UID Generator', ENGINE = INNODB
+`}},
+		{Version: 2, Description: "add LAST_TIMESTAMP", Applied: lastTimestampApplied, Statements: []string{`
+ALTER TABLE WORKER_NODE ADD COLUMN LAST_TIMESTAMP BIGINT NOT NULL DEFAULT 0 COMMENT 'last timestamp used by the worker id, unit as ms'
+`}},
+	}
+}
+
+// PostgresDialect Dialect of PostgreSQL, driven by github.com/lib/pq.
+// PostgreSQL doesn't support LastInsertId, the generated id is read by INSERT ... RETURNING
+type PostgresDialect struct {
+}
+
+func (p PostgresDialect) DriverName() string {
+	return "postgres"
+}
+
+func (p PostgresDialect) Rebind(query string) string {
+	builder := strings.Builder{}
+	n := 0
+	for _, c := range query {
+		if c != '?' {
+			builder.WriteRune(c)
+			continue
+		}
+		n++
+		builder.WriteByte('$')
+		builder.WriteString(strconv.Itoa(n))
+	}
+	return builder.String()
+}
+
+func (p PostgresDialect) InsertReturningId(ctx context.Context, db *sql.DB, query, idColumn string, args ...any) (int64, error) {
+	var id int64
+	err := db.QueryRowContext(ctx, strings.TrimRight(query, " \n")+" RETURNING "+idColumn, args...).Scan(&id)
+	return id, err
+}
+
+// IsMissingObject undefined_column 42703 or undefined_table 42P01
+func (p PostgresDialect) IsMissingObject(err error) bool {
+	var pqError *pq.Error
+	return errors.As(err, &pqError) && (pqError.Code == "42703" || pqError.Code == "42P01")
+}
+
+func (p PostgresDialect) Migrations() []Migration {
+	return []Migration{
+		{Version: 1, Description: "create WORKER_NODE", Statements: []string{`
+CREATE TABLE IF NOT EXISTS WORKER_NODE
+(
+    ID             BIGSERIAL   NOT NULL PRIMARY KEY,
+    HOST_NAME      VARCHAR(64) NOT NULL,
+    PORT           VARCHAR(64) NOT NULL,
+    TYPE           INT         NOT NULL,
+    LAUNCH_DATE    DATE        NOT NULL,
+    MODIFIED       TIMESTAMP   NOT NULL,
+    CREATED        TIMESTAMP   NOT NULL
+)
+`, `
+COMMENT ON TABLE WORKER_NODE IS 'DB WorkerID Assigner for UID Generator'
+`}},
+		{Version: 2, Description: "add LAST_TIMESTAMP", Applied: lastTimestampApplied, Statements: []string{`
+ALTER TABLE WORKER_NODE ADD COLUMN LAST_TIMESTAMP BIGINT NOT NULL DEFAULT 0
+`}},
+	}
+}
+
+// SQLiteDialect Dialect of SQLite, driven by the cgo-free modernc.org/sqlite
+type SQLiteDialect struct {
+}
+
+func (s SQLiteDialect) DriverName() string {
+	return "sqlite"
+}
+
+func (s SQLiteDialect) Rebind(query string) string {
+	return query
+}
+
+func (s SQLiteDialect) InsertReturningId(ctx context.Context, db *sql.DB, query, idColumn string, args ...any) (int64, error) {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// IsMissingObject SQLite reports them as the generic SQLITE_ERROR, they are told by the message
+func (s SQLiteDialect) IsMissingObject(err error) bool {
+	var sqliteError *sqlite.Error
+	if !errors.As(err, &sqliteError) {
+		return false
+	}
+	return strings.Contains(sqliteError.Error(), "no such column") || strings.Contains(sqliteError.Error(), "no such table")
+}
+
+// Migrations AUTOINCREMENT makes sure the ids are monotonic and never reused, even if rows are deleted
+func (s SQLiteDialect) Migrations() []Migration {
+	return []Migration{
+		{Version: 1, Description: "create WORKER_NODE", Statements: []string{`
+CREATE TABLE IF NOT EXISTS WORKER_NODE
+(
+    ID             INTEGER     NOT NULL PRIMARY KEY AUTOINCREMENT,
+    HOST_NAME      VARCHAR(64) NOT NULL,
+    PORT           VARCHAR(64) NOT NULL,
+    TYPE           INT         NOT NULL,
+    LAUNCH_DATE    DATE        NOT NULL,
+    MODIFIED       TIMESTAMP   NOT NULL,
+    CREATED        TIMESTAMP   NOT NULL
+)
+`}},
+		{Version: 2, Description: "add LAST_TIMESTAMP", Applied: lastTimestampApplied, Statements: []string{`
+ALTER TABLE WORKER_NODE ADD COLUMN LAST_TIMESTAMP BIGINT NOT NULL DEFAULT 0
+`}},
+	}
+}
@@ -0,0 +1,43 @@
+package uidgenerator
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestPostgresDialectRebind(t *testing.T) {
+	for query, want := range map[string]string{
+		"SELECT ID FROM WORKER_NODE":                                           "SELECT ID FROM WORKER_NODE",
+		"SELECT ID FROM WORKER_NODE WHERE ID = ?":                              "SELECT ID FROM WORKER_NODE WHERE ID = $1",
+		"UPDATE WORKER_NODE SET MODIFIED = ?, LAST_TIMESTAMP = ? WHERE ID = ?": "UPDATE WORKER_NODE SET MODIFIED = $1, LAST_TIMESTAMP = $2 WHERE ID = $3",
+	} {
+		if rebound := (PostgresDialect{}).Rebind(query); rebound != want {
+			t.Errorf("rebound %q, want %q", rebound, want)
+		}
+	}
+}
+
+// TestPostgresDialectInsertReturningId Runs the RETURNING statement on SQLite, which supports it as PostgreSQL does
+func TestPostgresDialectInsertReturningId(t *testing.T) {
+	db, err := sql.Open(SQLiteDialect{}.DriverName(), "file:"+filepath.Join(t.TempDir(), "uid_generator.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "CREATE TABLE WORKER_NODE (ID INTEGER PRIMARY KEY AUTOINCREMENT, HOST_NAME TEXT NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	for want := int64(1); want <= 2; want++ {
+		// the trailing blanks of the rendered statement are trimmed before RETURNING
+		id, err := PostgresDialect{}.InsertReturningId(ctx, db, "INSERT INTO WORKER_NODE (HOST_NAME) VALUES ('localhost')\n  ", "ID")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != want {
+			t.Errorf("id is %d, want %d", id, want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package uidgenerator
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteWorkerIdAssigner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "uid_generator.db")
+	lastWorkerId := int64(0)
+	for i := 0; i < 3; i++ {
+		workerIdAssigner, err := NewSQLiteWorkerIdAssigner(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		workerLease, err := workerIdAssigner.AssignWorkerId(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if workerLease.WorkerId() <= lastWorkerId {
+			t.Errorf("worker id %d is not greater than the last %d", workerLease.WorkerId(), lastWorkerId)
+		}
+		lastWorkerId = workerLease.WorkerId()
+		if err := workerIdAssigner.db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
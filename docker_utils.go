@@ -1,9 +1,7 @@
 package uidgenerator
 
 import (
-	"fmt"
 	"os"
-	"sync"
 )
 
 const (
@@ -12,39 +10,26 @@ const (
 	envKeyPortOriginal = "JPAAS_HOST_PORT_8080"
 )
 
-var dockerInfo dockerUtils
-
-func init() {
-	once := sync.Once{}
-	once.Do(func() {
-		dockerInfo.retrieveFromEnv()
-	})
-}
-
 type dockerUtils struct {
 	Host     string
 	Port     string
 	IsDocker bool
 }
 
-// Retrieve host & port from environment
-func (d *dockerUtils) retrieveFromEnv() {
-	d.Host = os.Getenv(envKeyHost)
-	d.Port = os.Getenv(envKeyPort)
-
+// retrieveFromEnv Retrieve host & port from environment on every call, both of them must be set or neither
+func retrieveFromEnv() (dockerUtils, error) {
+	d := dockerUtils{Host: os.Getenv(envKeyHost), Port: os.Getenv(envKeyPort)}
+	portKey := envKeyPort
 	if d.Port == "" {
 		d.Port = os.Getenv(envKeyPortOriginal)
+		portKey = envKeyPortOriginal
 	}
-
-	hasEnvHost := d.Host != ""
-	hasEnvPort := d.Port != ""
-
-	if hasEnvHost && hasEnvPort {
-		d.IsDocker = true
-	} else if !hasEnvHost && !hasEnvPort {
-		d.IsDocker = false
-	} else {
-		errorMsg := fmt.Sprintf("Missing host or port from env for Docker. host:%s, port:%s", d.Host, d.Port)
-		panic(errorMsg)
+	if d.Host == "" && d.Port == "" {
+		return d, nil
+	}
+	if _, _, err := hostIdentityFromEnv(envKeyHost, d.Host, portKey, d.Port); err != nil {
+		return d, err
 	}
+	d.IsDocker = true
+	return d, nil
 }
@@ -0,0 +1,278 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	defaultEtcdPrefix   = "/uid/workers/"
+	defaultEtcdLeaseTTL = 10 * time.Second
+)
+
+/*
+EtcdWorkerIdAssigner
+Represents an implementation of WorkerIdAssigner binding worker id to an etcd lease.
+
+Each worker id in [minWorkerId, maxWorkerId] is a key prefix<id>, a node grants a lease and claims the first free one
+by a transaction putting the key with the lease only if it's not created yet. The lease is kept alive by the client,
+once it's lost, such as revoked or expired during a partition, the key is deleted by etcd and the generator is fenced.
+
+It's a LastTimestampKeeper, the last timestamp of each worker id is kept in the key prefix<id>/last as ms.
+*/
+type EtcdWorkerIdAssigner struct {
+	client *clientv3.Client
+	// provider identifies the node in the value of the key, nil means JPAAS or the actual machine
+	provider HostIdentityProvider
+
+	prefix string
+	ttl    time.Duration
+	workerIdRange
+
+	leaseId        clientv3.LeaseID
+	lease          *renewableWorkerLease
+	stopKeepAlive  context.CancelFunc
+	keepAliveEnded chan struct{}
+}
+
+type OptionEtcd func(etcdWorkerIdAssigner *EtcdWorkerIdAssigner)
+
+// WithEtcdPrefix Prefix of the worker id keys, default as /uid/workers/
+func WithEtcdPrefix(prefix string) OptionEtcd {
+	return func(etcdWorkerIdAssigner *EtcdWorkerIdAssigner) {
+		etcdWorkerIdAssigner.prefix = prefix
+	}
+}
+
+// WithEtcdLeaseTTL TTL of the etcd lease, rounded up to seconds, default as 10s
+func WithEtcdLeaseTTL(ttl time.Duration) OptionEtcd {
+	return func(etcdWorkerIdAssigner *EtcdWorkerIdAssigner) {
+		etcdWorkerIdAssigner.ttl = ttl
+	}
+}
+
+// WithEtcdRange Claim worker id in [minWorkerId, maxWorkerId] only, default as [0, MaxWorkerId]
+func WithEtcdRange(minWorkerId, maxWorkerId int64) OptionEtcd {
+	return func(etcdWorkerIdAssigner *EtcdWorkerIdAssigner) {
+		etcdWorkerIdAssigner.setRange(minWorkerId, maxWorkerId)
+	}
+}
+
+// WithEtcdHostIdentity Where the host name and port in the value of the key come from, default as JPAAS or the actual machine
+func WithEtcdHostIdentity(provider HostIdentityProvider) OptionEtcd {
+	return func(etcdWorkerIdAssigner *EtcdWorkerIdAssigner) {
+		etcdWorkerIdAssigner.provider = provider
+	}
+}
+
+// NewEtcdWorkerIdAssigner Constructor with an etcd client
+func NewEtcdWorkerIdAssigner(client *clientv3.Client, opts ...OptionEtcd) (*EtcdWorkerIdAssigner, error) {
+	assigner := EtcdWorkerIdAssigner{
+		client:        client,
+		prefix:        defaultEtcdPrefix,
+		ttl:           defaultEtcdLeaseTTL,
+		workerIdRange: newWorkerIdRange(0),
+	}
+	for _, opt := range opts {
+		opt(&assigner)
+	}
+	if assigner.ttl < time.Second {
+		return nil, fmt.Errorf("etcd lease ttl %v must be at least 1s", assigner.ttl)
+	}
+	if assigner.minWorkerId < 0 || assigner.maxWorkerId < 0 {
+		return nil, errors.New("worker id range must not be negative")
+	}
+	if err := assigner.checkRange(); err != nil {
+		return nil, err
+	}
+	return &assigner, nil
+}
+
+func (e *EtcdWorkerIdAssigner) AssignWorkerId(ctx context.Context) (WorkerLease, error) {
+	if e.lease != nil {
+		return nil, fmt.Errorf("worker id %d is leased already", e.lease.WorkerId())
+	}
+	maxWorkerId, err := e.rangeMax()
+	if err != nil {
+		return nil, err
+	}
+	// find out the worker ids in use
+	response, err := e.client.Get(ctx, e.prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[int64]bool, len(response.Kvs))
+	for _, kv := range response.Kvs {
+		if workerId, err := strconv.ParseInt(strings.TrimPrefix(string(kv.Key), e.prefix), 10, 64); err == nil {
+			used[workerId] = true
+		}
+	}
+
+	granted := time.Now()
+	grant, err := e.client.Grant(ctx, int64((e.ttl+time.Second-1)/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	for workerId := e.minWorkerId; workerId <= maxWorkerId; workerId++ {
+		if used[workerId] {
+			continue
+		}
+		claimed, err := e.claim(ctx, grant, granted, workerId)
+		if err != nil {
+			e.revoke(grant.ID)
+			return nil, err
+		}
+		if claimed {
+			return e.lease, nil
+		}
+	}
+	e.revoke(grant.ID)
+	return nil, fmt.Errorf("%w: all worker ids [%d, %d] under %s are leased by alive nodes", ErrNoWorkerIdAvailable, e.minWorkerId, maxWorkerId, e.prefix)
+}
+
+// ClaimWorkerId Claim the specified worker id, returns ErrWorkerIdConflict if it's leased by another node
+func (e *EtcdWorkerIdAssigner) ClaimWorkerId(ctx context.Context, workerId int64) (WorkerLease, error) {
+	if e.lease != nil {
+		return nil, fmt.Errorf("worker id %d is leased already", e.lease.WorkerId())
+	}
+	granted := time.Now()
+	grant, err := e.client.Grant(ctx, int64((e.ttl+time.Second-1)/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	claimed, err := e.claim(ctx, grant, granted, workerId)
+	if err != nil || !claimed {
+		e.revoke(grant.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, fmt.Errorf("%w: %s is leased by another node", ErrWorkerIdConflict, e.key(workerId))
+	}
+	return e.lease, nil
+}
+
+// claim Put the key of workerId with the granted lease if it's not created yet, keep the lease alive once it's claimed
+func (e *EtcdWorkerIdAssigner) claim(ctx context.Context, grant *clientv3.LeaseGrantResponse, granted time.Time, workerId int64) (bool, error) {
+	workerNode, err := buildWorkerNode(e.provider)
+	if err != nil {
+		return false, err
+	}
+	key := e.key(workerId)
+	txn, err := e.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, workerNode.HostName+":"+workerNode.Port, clientv3.WithLease(grant.ID))).
+		Commit()
+	if err != nil || !txn.Succeeded {
+		return false, err
+	}
+	keepAliveCtx, stopKeepAlive := context.WithCancel(context.Background())
+	keepAlive, err := e.client.KeepAlive(keepAliveCtx, grant.ID)
+	if err != nil {
+		stopKeepAlive()
+		return false, err
+	}
+	e.leaseId = grant.ID
+	e.lease = newRenewableWorkerLease(workerId, granted.Add(time.Duration(grant.TTL)*time.Second))
+	e.stopKeepAlive = stopKeepAlive
+	e.keepAliveEnded = make(chan struct{})
+	go e.watchKeepAlive(e.lease, keepAlive, e.keepAliveEnded)
+	return true, nil
+}
+
+// watchKeepAlive Renew the deadline by the keep alive responses, the lease is lost when the channel is closed
+// or no response arrives before the deadline
+func (e *EtcdWorkerIdAssigner) watchKeepAlive(lease *renewableWorkerLease, keepAlive <-chan *clientv3.LeaseKeepAliveResponse, ended chan struct{}) {
+	defer close(ended)
+	for {
+		deadline, _ := lease.Deadline()
+		timer := time.NewTimer(time.Until(deadline))
+		select {
+		case response, ok := <-keepAlive:
+			timer.Stop()
+			if !ok {
+				lease.lose(errors.New("etcd lease keep alive closed"))
+				return
+			}
+			lease.renew(time.Now().Add(time.Duration(response.TTL) * time.Second))
+		case <-timer.C:
+			lease.lose(errors.New("etcd lease expired"))
+			return
+		}
+	}
+}
+
+// Release Stop keeping alive and revoke the lease, etcd deletes the worker id key with it
+func (e *EtcdWorkerIdAssigner) Release(ctx context.Context) error {
+	if e.lease == nil {
+		return nil
+	}
+	e.lease.lose(errors.New("released"))
+	e.stopKeepAlive()
+	<-e.keepAliveEnded
+	_, err := e.client.Revoke(ctx, e.leaseId)
+	e.lease = nil
+	return err
+}
+
+func (e *EtcdWorkerIdAssigner) LoadLastTimestamp(ctx context.Context, workerId int64) (time.Time, error) {
+	response, err := e.client.Get(ctx, e.key(workerId)+"/last")
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(response.Kvs) == 0 {
+		return time.Time{}, nil
+	}
+	lastTimestamp, err := strconv.ParseInt(string(response.Kvs[0].Value), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid last timestamp of worker id %d: %w", workerId, err)
+	}
+	return time.UnixMilli(lastTimestamp), nil
+}
+
+// SaveLastTimestamp Compare and swap on the mod revision, so the saved timestamp never goes back
+func (e *EtcdWorkerIdAssigner) SaveLastTimestamp(ctx context.Context, workerId int64, lastTimestamp time.Time) error {
+	key := e.key(workerId) + "/last"
+	for {
+		response, err := e.client.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		modRevision := int64(0)
+		if len(response.Kvs) != 0 {
+			saved, _ := strconv.ParseInt(string(response.Kvs[0].Value), 10, 64)
+			if saved >= lastTimestamp.UnixMilli() {
+				return nil
+			}
+			modRevision = response.Kvs[0].ModRevision
+		}
+		txn, err := e.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", modRevision)).
+			Then(clientv3.OpPut(key, strconv.FormatInt(lastTimestamp.UnixMilli(), 10))).
+			Commit()
+		if err != nil || txn.Succeeded {
+			return err
+		}
+	}
+}
+
+func (e *EtcdWorkerIdAssigner) revoke(leaseId clientv3.LeaseID) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl)
+	defer cancel()
+	_, _ = e.client.Revoke(ctx, leaseId)
+}
+
+// key The worker ids of each datacenter are kept apart as <prefix>dc<datacenterId>/<workerId>
+func (e *EtcdWorkerIdAssigner) key(workerId int64) string {
+	if e.bounds.Datacenter {
+		return e.prefix + "dc" + strconv.FormatInt(e.bounds.DatacenterId, 10) + "/" + strconv.FormatInt(workerId, 10)
+	}
+	return e.prefix + strconv.FormatInt(workerId, 10)
+}
@@ -0,0 +1,169 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+func freeURL(t *testing.T) url.URL {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return url.URL{Scheme: "http", Host: listener.Addr().String()}
+}
+
+func startEmbedEtcd(t *testing.T) *clientv3.Client {
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+	clientURL, peerURL := freeURL(t), freeURL(t)
+	cfg.ListenClientUrls, cfg.AdvertiseClientUrls = []url.URL{clientURL}, []url.URL{clientURL}
+	cfg.ListenPeerUrls, cfg.AdvertisePeerUrls = []url.URL{peerURL}, []url.URL{peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	server, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	select {
+	case <-server.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("embed etcd is not ready")
+	}
+	client, err := clientv3.New(clientv3.Config{Endpoints: []string{clientURL.String()}, DialTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestEtcdWorkerIdAssigner(t *testing.T) {
+	client := startEmbedEtcd(t)
+	ctx := context.Background()
+	newAssigner := func() *EtcdWorkerIdAssigner {
+		assigner, err := NewEtcdWorkerIdAssigner(client, WithEtcdRange(0, 1), WithEtcdLeaseTTL(2*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return assigner
+	}
+
+	lost := make(chan error, 1)
+	first := newAssigner()
+	defaultUidGenerator, err := NewDefaultUidGenerator(first, WithWorkerLeaseLostHandler(func(err error) {
+		lost <- err
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedUidGenerator, err := NewCachedUidGenerator(defaultUidGenerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := newAssigner()
+	secondLease, err := second.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultUidGenerator.workerId != 0 || secondLease.WorkerId() != 1 {
+		t.Fatalf("worker ids are %d and %d, want 0 and 1", defaultUidGenerator.workerId, secondLease.WorkerId())
+	}
+	if _, err := newAssigner().AssignWorkerId(ctx); !errors.Is(err, ErrNoWorkerIdAvailable) {
+		t.Fatalf("err is %v, want ErrNoWorkerIdAvailable", err)
+	}
+
+	// the lease is kept alive beyond the ttl
+	time.Sleep(3 * time.Second)
+	if _, err := cachedUidGenerator.GetUID(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the released worker id can be claimed again
+	if err := second.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newAssigner().AssignWorkerId(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// losing the lease calls the handler and stops both generators
+	if _, err := client.Revoke(ctx, first.leaseId); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-lost:
+		if !errors.Is(err, ErrWorkerLeaseLost) {
+			t.Errorf("err is %v, want ErrWorkerLeaseLost", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lease lost handler is not called")
+	}
+	if _, err := cachedUidGenerator.GetUID(); !errors.Is(err, ErrWorkerLeaseLost) {
+		t.Errorf("err is %v, want ErrWorkerLeaseLost", err)
+	}
+	if _, err := defaultUidGenerator.GetUID(); !errors.Is(err, ErrWorkerLeaseLost) {
+		t.Errorf("err is %v, want ErrWorkerLeaseLost", err)
+	}
+}
+
+func TestEtcdWorkerIdRange(t *testing.T) {
+	client := startEmbedEtcd(t)
+	ctx := context.Background()
+	// [0, 0] is the range of worker id 0 only, not up to the bounds
+	first, err := NewEtcdWorkerIdAssigner(client, WithEtcdRange(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workerLease, err := first.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = first.Release(ctx) })
+	if workerLease.WorkerId() != 0 {
+		t.Errorf("worker id is %d, want 0", workerLease.WorkerId())
+	}
+	second, err := NewEtcdWorkerIdAssigner(client, WithEtcdRange(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.AssignWorkerId(ctx); !errors.Is(err, ErrNoWorkerIdAvailable) {
+		t.Errorf("err is %v, want ErrNoWorkerIdAvailable", err)
+	}
+
+	// the empty range is refused on construction
+	if _, err := NewEtcdWorkerIdAssigner(client, WithEtcdRange(3, 1)); err == nil {
+		t.Error("etcd range [3, 1] should be refused")
+	}
+}
+
+func TestEtcdHostIdentity(t *testing.T) {
+	client := startEmbedEtcd(t)
+	ctx := context.Background()
+	t.Setenv("HOSTNAME", "uid-host")
+	assigner, err := NewEtcdWorkerIdAssigner(client, WithEtcdHostIdentity(HostnameHostIdentityProvider{Port: "8080"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workerLease, err := assigner.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = assigner.Release(ctx) })
+	response, err := client.Get(ctx, assigner.key(workerLease.WorkerId()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Kvs) != 1 || string(response.Kvs[0].Value) != "uid-host:8080" {
+		t.Errorf("key values are %v, want uid-host:8080", response.Kvs)
+	}
+}
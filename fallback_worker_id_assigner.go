@@ -0,0 +1,410 @@
+package uidgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+const (
+	// defaultFallbackPrimaryTimeout Timeout of the primary assigner, a black holed database should not hang the boot
+	defaultFallbackPrimaryTimeout = 5 * time.Second
+	// defaultFallbackCacheInterval Interval to persist the renewed deadline of the primary lease
+	defaultFallbackCacheInterval = 5 * time.Second
+	// defaultFallbackLastTimestampMargin The last timestamp cached may be stale by the save interval of the generator
+	defaultFallbackLastTimestampMargin = defaultLastTimestampSaveInterval
+)
+
+// WorkerIdSource Where FallbackWorkerIdAssigner got the worker id from
+type WorkerIdSource string
+
+const (
+	// WorkerIdSourcePrimary The worker id is assigned by the primary assigner
+	WorkerIdSourcePrimary WorkerIdSource = "primary"
+	// WorkerIdSourceCache The primary assigner failed, the worker id is the last known one from the cache file
+	WorkerIdSourceCache WorkerIdSource = "cache"
+)
+
+/*
+FallbackWorkerIdAssigner
+Represents a WorkerIdAssigner chaining a primary assigner (database, etcd, ...) with a local cache file.
+
+After every successful assignment the worker id and its lease deadline are persisted to the cache file,
+the deadline is refreshed while the primary renews the lease. If the primary fails on the next boot,
+the cached worker id is used until the cached deadline, no other node can get it before that.
+A worker id without deadline (such as assigned by DisposableWorkerIdAssigner) is owned forever, it can always be used.
+
+The process holds an exclusive flock on <cacheFile>.lock until Release, the cache file is written only by the holder.
+It falls back to the cache only with the lock, so the previous owner on the host, such as the old process during a deploy, is gone.
+The last timestamp cached is pushed forward by the margin of WithFallbackLastTimestampMargin, it may be stale by a save interval.
+
+It's a LastTimestampKeeper, the last timestamp is kept in the cache file too, and forwarded to the primary if it's a keeper.
+*/
+type FallbackWorkerIdAssigner struct {
+	primary        WorkerIdAssigner
+	cacheFile      string
+	primaryTimeout time.Duration
+	cacheInterval  time.Duration
+	sourceHandler  func(source WorkerIdSource, workerLease WorkerLease)
+	bounds         WorkerIdBounds
+	// lastTimestampMargin Pushes the last timestamp restored from the cache forward
+	lastTimestampMargin time.Duration
+
+	mutex sync.Mutex
+	// lock The flock of the cache file, nil unless held
+	lock   *flock.Flock
+	source WorkerIdSource
+	cache  workerIdCache
+	expire *time.Timer
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// workerIdCache Content of the cache file, the times are unix ms
+type workerIdCache struct {
+	WorkerId     int64 `json:"workerId"`
+	DatacenterId int64 `json:"datacenterId,omitempty"`
+	// Deadline Zero means the worker id never expires
+	Deadline      int64 `json:"deadline,omitempty"`
+	LastTimestamp int64 `json:"lastTimestamp,omitempty"`
+}
+
+type OptionFallback func(fallbackWorkerIdAssigner *FallbackWorkerIdAssigner)
+
+// WithFallbackPrimaryTimeout Timeout of the primary assigner, default as 5s, zero means no timeout
+func WithFallbackPrimaryTimeout(timeout time.Duration) OptionFallback {
+	return func(fallbackWorkerIdAssigner *FallbackWorkerIdAssigner) {
+		fallbackWorkerIdAssigner.primaryTimeout = timeout
+	}
+}
+
+// WithFallbackCacheInterval Interval to persist the deadline of the primary lease, default as 5s
+func WithFallbackCacheInterval(interval time.Duration) OptionFallback {
+	return func(fallbackWorkerIdAssigner *FallbackWorkerIdAssigner) {
+		fallbackWorkerIdAssigner.cacheInterval = interval
+	}
+}
+
+// WithFallbackLastTimestampMargin Push the last timestamp restored from the cache forward by margin, default as 3s.
+// It must not be less than the save interval of the generator, see WithLastTimestampSaveInterval
+func WithFallbackLastTimestampMargin(margin time.Duration) OptionFallback {
+	return func(fallbackWorkerIdAssigner *FallbackWorkerIdAssigner) {
+		fallbackWorkerIdAssigner.lastTimestampMargin = margin
+	}
+}
+
+// WithWorkerIdSourceHandler Called after every assignment with where the worker id came from
+func WithWorkerIdSourceHandler(handler func(source WorkerIdSource, workerLease WorkerLease)) OptionFallback {
+	return func(fallbackWorkerIdAssigner *FallbackWorkerIdAssigner) {
+		fallbackWorkerIdAssigner.sourceHandler = handler
+	}
+}
+
+// NewFallbackWorkerIdAssigner Constructor with the primary assigner and the cache file, such as /var/lib/uid/worker.json
+func NewFallbackWorkerIdAssigner(primary WorkerIdAssigner, cacheFile string, opts ...OptionFallback) (*FallbackWorkerIdAssigner, error) {
+	assigner := FallbackWorkerIdAssigner{
+		primary:        primary,
+		cacheFile:      cacheFile,
+		primaryTimeout: defaultFallbackPrimaryTimeout,
+		cacheInterval:  defaultFallbackCacheInterval,
+		bounds:         WorkerIdBounds{MaxWorkerId: ^(-1 << defaultWorkerBits)},
+
+		lastTimestampMargin: defaultFallbackLastTimestampMargin,
+	}
+	for _, opt := range opts {
+		opt(&assigner)
+	}
+	if primary == nil {
+		return nil, errors.New("primary worker id assigner is required")
+	}
+	if assigner.cacheInterval <= 0 {
+		return nil, errors.New("cache interval must be positive")
+	}
+	if assigner.lastTimestampMargin < 0 {
+		return nil, errors.New("last timestamp margin must not be negative")
+	}
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0o755); err != nil {
+		return nil, err
+	}
+	return &assigner, nil
+}
+
+func (f *FallbackWorkerIdAssigner) SetWorkerIdBounds(bounds WorkerIdBounds) {
+	f.bounds = bounds
+	if bounded, ok := f.primary.(BoundedWorkerIdAssigner); ok {
+		bounded.SetWorkerIdBounds(bounds)
+	}
+}
+
+// Source Where the worker id came from, empty before assigning
+func (f *FallbackWorkerIdAssigner) Source() WorkerIdSource {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.source
+}
+
+func (f *FallbackWorkerIdAssigner) AssignWorkerId(ctx context.Context) (WorkerLease, error) {
+	primaryCtx := ctx
+	if f.primaryTimeout > 0 {
+		var cancel context.CancelFunc
+		primaryCtx, cancel = context.WithTimeout(ctx, f.primaryTimeout)
+		defer cancel()
+	}
+	workerLease, err := f.primary.AssignWorkerId(primaryCtx)
+	if err == nil {
+		f.assignFromPrimary(workerLease)
+	} else {
+		log.Printf("primary worker id assigner failed: %v, falling back to the cache file %s", err, f.cacheFile)
+		if workerLease, err = f.assignFromCache(err); err != nil {
+			return nil, err
+		}
+	}
+	if f.sourceHandler != nil {
+		f.sourceHandler(f.Source(), workerLease)
+	}
+	return workerLease, nil
+}
+
+// tryLock Take the flock of the cache file without blocking, it's held until Release
+func (f *FallbackWorkerIdAssigner) tryLock() (bool, error) {
+	if f.lock != nil {
+		return true, nil
+	}
+	lock := flock.New(f.cacheFile + ".lock")
+	locked, err := lock.TryLock()
+	if err != nil || !locked {
+		return false, err
+	}
+	f.lock = lock
+	return true, nil
+}
+
+func (f *FallbackWorkerIdAssigner) assignFromPrimary(workerLease WorkerLease) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.source = WorkerIdSourcePrimary
+	// another process on the host owns the cache file, the worker id is not cached
+	if locked, err := f.tryLock(); !locked {
+		log.Printf("cache file %s is locked by another process, worker id %d is not cached: %v", f.cacheFile, workerLease.WorkerId(), err)
+		return
+	}
+	cache, err := f.readCache()
+	if err != nil || cache.WorkerId != workerLease.WorkerId() || cache.DatacenterId != f.bounds.DatacenterId {
+		cache = workerIdCache{WorkerId: workerLease.WorkerId(), DatacenterId: f.bounds.DatacenterId}
+	}
+	cache.Deadline = 0
+	if deadline, ok := workerLease.Deadline(); ok {
+		cache.Deadline = deadline.UnixMilli()
+	}
+	f.cache = cache
+	if err := f.writeCache(); err != nil {
+		log.Printf("failed to cache worker id %d: %v", cache.WorkerId, err)
+	}
+	if _, ok := workerLease.Deadline(); ok {
+		f.stop = make(chan struct{})
+		f.done = make(chan struct{})
+		go f.keepCache(workerLease)
+	}
+}
+
+func (f *FallbackWorkerIdAssigner) assignFromCache(primaryErr error) (WorkerLease, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	// the previous owner of the cached worker id may be alive on the host
+	locked, err := f.tryLock()
+	if err != nil {
+		return nil, fmt.Errorf("%w, and failed to lock the cache file: %v", primaryErr, err)
+	}
+	if !locked {
+		return nil, fmt.Errorf("%w, and the cache file %s is locked by another process", primaryErr, f.cacheFile)
+	}
+	cache, err := f.readCache()
+	if err != nil {
+		f.unlock()
+		return nil, fmt.Errorf("%w, and no cached worker id to fall back to: %v", primaryErr, err)
+	}
+	if cache.DatacenterId != f.bounds.DatacenterId {
+		f.unlock()
+		return nil, fmt.Errorf("%w, and cached worker id %d is of datacenter %d", primaryErr, cache.WorkerId, cache.DatacenterId)
+	}
+	if cache.WorkerId < 0 || cache.WorkerId > f.bounds.MaxWorkerId {
+		f.unlock()
+		return nil, fmt.Errorf("%w, and cached worker id %d exceeds the max %d", primaryErr, cache.WorkerId, f.bounds.MaxWorkerId)
+	}
+	f.source = WorkerIdSourceCache
+	f.cache = cache
+	if cache.Deadline == 0 {
+		log.Printf("worker id %d is assigned from the cache file %s", cache.WorkerId, f.cacheFile)
+		return NewWorkerLease(cache.WorkerId), nil
+	}
+	deadline := time.UnixMilli(cache.Deadline)
+	if !time.Now().Before(deadline) {
+		f.unlock()
+		return nil, fmt.Errorf("%w, and cached lease of worker id %d expired at %s", primaryErr, cache.WorkerId, deadline.Format(time.RFC3339))
+	}
+	// the lease can't be renewed without the primary, the generator is fenced at the cached deadline
+	workerLease := newRenewableWorkerLease(cache.WorkerId, deadline)
+	f.expire = time.AfterFunc(time.Until(deadline), func() {
+		workerLease.lose(errors.New("cached lease expired"))
+	})
+	log.Printf("worker id %d is assigned from the cache file %s, valid until %s", cache.WorkerId, f.cacheFile, deadline.Format(time.RFC3339))
+	return workerLease, nil
+}
+
+// keepCache Persist the deadline renewed by the primary, an expired deadline is persisted once the lease is lost
+func (f *FallbackWorkerIdAssigner) keepCache(workerLease WorkerLease) {
+	defer close(f.done)
+	ticker := time.NewTicker(f.cacheInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-workerLease.Done():
+			f.expireCache()
+			return
+		case <-ticker.C:
+		}
+		deadline, _ := workerLease.Deadline()
+		f.mutex.Lock()
+		f.cache.Deadline = deadline.UnixMilli()
+		if err := f.writeCache(); err != nil {
+			log.Printf("failed to cache worker id %d: %v", f.cache.WorkerId, err)
+		}
+		f.mutex.Unlock()
+	}
+}
+
+// expireCache Make sure the cached worker id is not used after it's lost or released
+func (f *FallbackWorkerIdAssigner) expireCache() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.lock == nil {
+		return
+	}
+	f.cache.Deadline = time.Now().UnixMilli()
+	if err := f.writeCache(); err != nil {
+		log.Printf("failed to expire cached worker id %d: %v", f.cache.WorkerId, err)
+	}
+}
+
+// unlock Release the flock of the cache file, with the mutex held
+func (f *FallbackWorkerIdAssigner) unlock() {
+	if f.lock == nil {
+		return
+	}
+	if err := f.lock.Unlock(); err != nil {
+		log.Printf("failed to unlock the cache file %s: %v", f.cacheFile, err)
+	}
+	f.lock = nil
+}
+
+// Release Release the primary lease and expire the cache, a worker id from the cache stays cached until its deadline.
+// The flock of the cache file is released at last
+func (f *FallbackWorkerIdAssigner) Release(ctx context.Context) error {
+	f.mutex.Lock()
+	source, stop, done, expire := f.source, f.stop, f.done, f.expire
+	f.stop, f.done, f.expire = nil, nil, nil
+	f.mutex.Unlock()
+	defer func() {
+		f.mutex.Lock()
+		f.unlock()
+		f.mutex.Unlock()
+	}()
+	if expire != nil {
+		expire.Stop()
+	}
+	if source != WorkerIdSourcePrimary {
+		return nil
+	}
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	if err := f.primary.Release(ctx); err != nil {
+		return err
+	}
+	if stop != nil {
+		f.expireCache()
+	}
+	return nil
+}
+
+/*
+LoadLastTimestamp
+The later of the cache file and the primary, the primary is skipped if it failed to assign.
+The worker id from the cache was saved a save interval ago at most, the timestamp is pushed forward by the margin,
+or from now on without any timestamp cached.
+*/
+func (f *FallbackWorkerIdAssigner) LoadLastTimestamp(ctx context.Context, workerId int64) (time.Time, error) {
+	f.mutex.Lock()
+	var lastTimestamp time.Time
+	if f.cache.WorkerId == workerId && f.cache.LastTimestamp > 0 {
+		lastTimestamp = time.UnixMilli(f.cache.LastTimestamp)
+	}
+	source := f.source
+	f.mutex.Unlock()
+	if source == WorkerIdSourceCache {
+		if lastTimestamp.IsZero() {
+			lastTimestamp = time.Now()
+		}
+		return lastTimestamp.Add(f.lastTimestampMargin), nil
+	}
+	if keeper, ok := f.primary.(LastTimestampKeeper); ok && source == WorkerIdSourcePrimary {
+		primaryTimestamp, err := keeper.LoadLastTimestamp(ctx, workerId)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if primaryTimestamp.After(lastTimestamp) {
+			lastTimestamp = primaryTimestamp
+		}
+	}
+	return lastTimestamp, nil
+}
+
+func (f *FallbackWorkerIdAssigner) SaveLastTimestamp(ctx context.Context, workerId int64, lastTimestamp time.Time) error {
+	f.mutex.Lock()
+	var err error
+	if f.lock != nil && f.cache.WorkerId == workerId && lastTimestamp.UnixMilli() > f.cache.LastTimestamp {
+		f.cache.LastTimestamp = lastTimestamp.UnixMilli()
+		err = f.writeCache()
+	}
+	source := f.source
+	f.mutex.Unlock()
+	if keeper, ok := f.primary.(LastTimestampKeeper); ok && source == WorkerIdSourcePrimary {
+		if primaryErr := keeper.SaveLastTimestamp(ctx, workerId, lastTimestamp); primaryErr != nil {
+			return primaryErr
+		}
+	}
+	return err
+}
+
+func (f *FallbackWorkerIdAssigner) readCache() (workerIdCache, error) {
+	var cache workerIdCache
+	data, err := os.ReadFile(f.cacheFile)
+	if err != nil {
+		return cache, err
+	}
+	err = json.Unmarshal(data, &cache)
+	return cache, err
+}
+
+// writeCache Write to a temporary file and rename, a crash never leaves a broken cache file
+func (f *FallbackWorkerIdAssigner) writeCache() error {
+	data, err := json.Marshal(f.cache)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(f.cacheFile+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.cacheFile+".tmp", f.cacheFile)
+}
@@ -0,0 +1,86 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestFallbackWorkerIdAssigner(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	cacheFile := filepath.Join(t.TempDir(), "worker.json")
+	var sources []WorkerIdSource
+	newAssigner := func() *FallbackWorkerIdAssigner {
+		primary, err := NewRedisWorkerIdAssigner(client, WithRedisRange(3, 3),
+			WithRedisLeaseTTL(time.Second), WithRedisRenewInterval(100*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assigner, err := NewFallbackWorkerIdAssigner(primary, cacheFile,
+			WithFallbackPrimaryTimeout(200*time.Millisecond), WithFallbackCacheInterval(50*time.Millisecond),
+			WithFallbackLastTimestampMargin(300*time.Millisecond),
+			WithWorkerIdSourceHandler(func(source WorkerIdSource, workerLease WorkerLease) {
+				sources = append(sources, source)
+			}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return assigner
+	}
+
+	// the first boot caches the worker id assigned by redis
+	first := newAssigner()
+	firstLease, err := first.AssignWorkerId(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	// the next boot is refused while the first one holds the cache file
+	server.Close()
+	if _, err := newAssigner().AssignWorkerId(context.Background()); err == nil {
+		t.Fatal("cached worker id is used while the first one is alive")
+	}
+
+	// the first one dies without release, the next boot falls back to the cached worker id
+	close(first.stop)
+	<-first.done
+	first.mutex.Lock()
+	first.unlock()
+	first.mutex.Unlock()
+	restored := time.Now().Add(300 * time.Millisecond)
+	defaultUidGenerator, err := NewDefaultUidGenerator(newAssigner(), WithLastTimestampWait(time.Second),
+		WithTimeUnit(TimeUnitMillisecond), WithBits(41, 10, 12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultUidGenerator.lastTimestamp < defaultUidGenerator.timeUnit.timestamp(restored) {
+		t.Errorf("last timestamp %d, want pushed forward to %d", defaultUidGenerator.lastTimestamp, defaultUidGenerator.timeUnit.timestamp(restored))
+	}
+	if defaultUidGenerator.workerId != firstLease.WorkerId() {
+		t.Errorf("worker id is %d, want the cached %d", defaultUidGenerator.workerId, firstLease.WorkerId())
+	}
+	if len(sources) != 2 || sources[0] != WorkerIdSourcePrimary || sources[1] != WorkerIdSourceCache {
+		t.Errorf("sources are %v, want [primary cache]", sources)
+	}
+	if _, err := defaultUidGenerator.GetUID(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the cached lease can't be renewed, the generator is fenced at the deadline
+	deadline, _ := defaultUidGenerator.workerLease.Deadline()
+	time.Sleep(time.Until(deadline) + 100*time.Millisecond)
+	if _, err := defaultUidGenerator.GetUID(); !errors.Is(err, ErrWorkerLeaseLost) {
+		t.Errorf("err is %v, want ErrWorkerLeaseLost", err)
+	}
+	if _, err := newAssigner().AssignWorkerId(context.Background()); err == nil {
+		t.Error("expired cached lease should be refused")
+	}
+}
@@ -1,23 +1,24 @@
 package uidgenerator
 
 import (
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 )
 
 func TestPadding(t *testing.T) {
-	workerIdAssigner, err := NewDisposableWorkerIdAssigner("root:syr1120@xys.com@tcp(127.0.0.1:3306)/uid_generator?charset=utf8mb4&parseTime=true&loc=Local")
+	workerIdAssigner, err := NewSQLiteWorkerIdAssigner(filepath.Join(t.TempDir(), "uid_generator.db"))
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	defaultUidGenerator, err := NewDefaultUidGenerator(workerIdAssigner)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	cachedUidGenerator, err := NewCachedUidGenerator(defaultUidGenerator)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	var id int64
 	now := time.Now()
@@ -37,3 +38,17 @@ func TestPadding(t *testing.T) {
 	wg.Wait()
 	t.Log(time.Since(now))
 }
+
+func TestPaddingShutdownTwice(t *testing.T) {
+	defaultUidGenerator, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedUidGenerator, err := NewCachedUidGenerator(defaultUidGenerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// shutting down the padding executor twice doesn't panic
+	cachedUidGenerator.bufferPaddingExecutor.shutdown()
+	cachedUidGenerator.bufferPaddingExecutor.shutdown()
+}
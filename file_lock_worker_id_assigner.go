@@ -0,0 +1,151 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+/*
+FileLockWorkerIdAssigner
+Represents an implementation of WorkerIdAssigner for the processes on the same host without any database.
+
+Each worker id in [minWorkerId, maxWorkerId] has a lock file dir/worker-<id>.lock, a process claims
+the first worker id whose file it can take an exclusive flock on. The lock is held for the life of the process,
+and the operating system releases it automatically when the process dies, so the worker id can be claimed again.
+
+It's a LastTimestampKeeper, the last timestamp of each worker id is kept in dir/worker-<id>.timestamp as ms.
+*/
+type FileLockWorkerIdAssigner struct {
+	workerIdRange
+	dir  string
+	lock *flock.Flock
+}
+
+type OptionFileLock func(fileLockWorkerIdAssigner *FileLockWorkerIdAssigner)
+
+// WithFileLockRange Claim worker id in [minWorkerId, maxWorkerId] only, default as [0, MaxWorkerId]
+func WithFileLockRange(minWorkerId, maxWorkerId int64) OptionFileLock {
+	return func(fileLockWorkerIdAssigner *FileLockWorkerIdAssigner) {
+		fileLockWorkerIdAssigner.setRange(minWorkerId, maxWorkerId)
+	}
+}
+
+// NewFileLockWorkerIdAssigner Constructor with the directory of lock files, such as /var/lib/uid
+func NewFileLockWorkerIdAssigner(dir string, opts ...OptionFileLock) (*FileLockWorkerIdAssigner, error) {
+	assigner := FileLockWorkerIdAssigner{
+		workerIdRange: newWorkerIdRange(0),
+		dir:           dir,
+	}
+	for _, opt := range opts {
+		opt(&assigner)
+	}
+	if assigner.minWorkerId < 0 || assigner.maxWorkerId < 0 {
+		return nil, errors.New("worker id range must not be negative")
+	}
+	if err := assigner.checkRange(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &assigner, nil
+}
+
+func (f *FileLockWorkerIdAssigner) AssignWorkerId(ctx context.Context) (WorkerLease, error) {
+	if f.lock != nil {
+		return nil, fmt.Errorf("worker id lock %s is held already", f.lock.Path())
+	}
+	maxWorkerId, err := f.rangeMax()
+	if err != nil {
+		return nil, err
+	}
+	for workerId := f.minWorkerId; workerId <= maxWorkerId; workerId++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		locked, err := f.claim(workerId)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			return NewWorkerLease(workerId), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: all worker ids [%d, %d] under %s are locked by other processes", ErrNoWorkerIdAvailable, f.minWorkerId, maxWorkerId, f.dir)
+}
+
+// ClaimWorkerId Claim the specified worker id, returns ErrWorkerIdConflict if it's locked by another process
+func (f *FileLockWorkerIdAssigner) ClaimWorkerId(ctx context.Context, workerId int64) (WorkerLease, error) {
+	if f.lock != nil {
+		return nil, fmt.Errorf("worker id lock %s is held already", f.lock.Path())
+	}
+	locked, err := f.claim(workerId)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, fmt.Errorf("%w: %s is locked by another process", ErrWorkerIdConflict, f.path(workerId, "lock"))
+	}
+	return NewWorkerLease(workerId), nil
+}
+
+// claim Try to lock the file of workerId without blocking
+func (f *FileLockWorkerIdAssigner) claim(workerId int64) (bool, error) {
+	lock := flock.New(f.path(workerId, "lock"))
+	locked, err := lock.TryLock()
+	if err != nil || !locked {
+		return false, err
+	}
+	f.lock = lock
+	return true, nil
+}
+
+// Release Unlock the file, another process is able to claim the worker id then
+func (f *FileLockWorkerIdAssigner) Release(ctx context.Context) error {
+	if f.lock == nil {
+		return nil
+	}
+	err := f.lock.Unlock()
+	f.lock = nil
+	return err
+}
+
+func (f *FileLockWorkerIdAssigner) LoadLastTimestamp(ctx context.Context, workerId int64) (time.Time, error) {
+	content, err := os.ReadFile(f.path(workerId, "timestamp"))
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	lastTimestamp, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid last timestamp of worker id %d: %w", workerId, err)
+	}
+	return time.UnixMilli(lastTimestamp), nil
+}
+
+// SaveLastTimestamp Only the holder of the lock writes the file, it's written to a temp file and renamed to be atomic
+func (f *FileLockWorkerIdAssigner) SaveLastTimestamp(ctx context.Context, workerId int64, lastTimestamp time.Time) error {
+	saved, err := f.LoadLastTimestamp(ctx, workerId)
+	if err != nil || saved.After(lastTimestamp) {
+		return err
+	}
+	path := f.path(workerId, "timestamp")
+	if err := os.WriteFile(path+".tmp", []byte(strconv.FormatInt(lastTimestamp.UnixMilli(), 10)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (f *FileLockWorkerIdAssigner) path(workerId int64, ext string) string {
+	return filepath.Join(f.dir, fmt.Sprintf("worker-%d.%s", workerId, ext))
+}
@@ -0,0 +1,154 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFileLockWorkerIdAssigner(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	assigners := make([]*FileLockWorkerIdAssigner, 0, 3)
+	for i := 0; i < 3; i++ {
+		assigner, err := NewFileLockWorkerIdAssigner(dir, WithFileLockRange(1, 3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		workerLease, err := assigner.AssignWorkerId(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if workerLease.WorkerId() != int64(i+1) {
+			t.Errorf("worker id is %d, want %d", workerLease.WorkerId(), i+1)
+		}
+		assigners = append(assigners, assigner)
+	}
+
+	// every slot is taken
+	assigner, err := NewFileLockWorkerIdAssigner(dir, WithFileLockRange(1, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := assigner.AssignWorkerId(ctx); !errors.Is(err, ErrNoWorkerIdAvailable) {
+		t.Fatalf("err is %v, want ErrNoWorkerIdAvailable", err)
+	}
+
+	// the released worker id can be claimed again
+	if err := assigners[1].Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	workerLease, err := assigner.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workerLease.WorkerId() != 2 {
+		t.Errorf("worker id is %d, want 2", workerLease.WorkerId())
+	}
+
+	// the range must fit in the worker bits
+	assigner, err = NewFileLockWorkerIdAssigner(dir, WithFileLockRange(0, 1<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewDefaultUidGenerator(assigner, WithBits(31, 10, 22)); err == nil {
+		t.Error("range exceeds the max worker id should be refused")
+	}
+}
+
+func TestFileLockWorkerIdRange(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	// [0, 0] is the range of worker id 0 only, not up to the bounds
+	first, err := NewFileLockWorkerIdAssigner(dir, WithFileLockRange(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workerLease, err := first.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workerLease.WorkerId() != 0 {
+		t.Errorf("worker id is %d, want 0", workerLease.WorkerId())
+	}
+	second, err := NewFileLockWorkerIdAssigner(dir, WithFileLockRange(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.AssignWorkerId(ctx); !errors.Is(err, ErrNoWorkerIdAvailable) {
+		t.Errorf("err is %v, want ErrNoWorkerIdAvailable", err)
+	}
+
+	// the empty range is refused on construction
+	if _, err := NewFileLockWorkerIdAssigner(dir, WithFileLockRange(3, 1)); err == nil {
+		t.Error("file lock range [3, 1] should be refused")
+	}
+}
+
+func TestLastTimestampKeeper(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	assigner, err := NewFileLockWorkerIdAssigner(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultUidGenerator, err := NewDefaultUidGenerator(assigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedUidGenerator, err := NewCachedUidGenerator(defaultUidGenerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cachedUidGenerator.GetUID(); err != nil {
+		t.Fatal(err)
+	}
+	borrowedSecond := cachedUidGenerator.bufferPaddingExecutor.lastTimestamp.Load()
+	if err := cachedUidGenerator.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	lastTimestamp, err := assigner.LoadLastTimestamp(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastTimestamp.Unix() != borrowedSecond {
+		t.Fatalf("last timestamp is %d, want the borrowed second %d", lastTimestamp.Unix(), borrowedSecond)
+	}
+
+	// the next owner refuses to generate uid until the clock passes the last timestamp
+	assigner, err = NewFileLockWorkerIdAssigner(dir, WithFileLockRange(1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(2 * time.Second)
+	if err := assigner.SaveLastTimestamp(ctx, 1, future); err != nil {
+		t.Fatal(err)
+	}
+	defaultUidGenerator, err = NewDefaultUidGenerator(assigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := defaultUidGenerator.GetUID(); err == nil {
+		t.Error("uid is generated before the last timestamp")
+	}
+	if err := defaultUidGenerator.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// or waits for it
+	defaultUidGenerator, err = NewDefaultUidGenerator(assigner, WithLastTimestampWait(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitingUidGenerator := defaultUidGenerator
+	// stop saving before the temp dir is removed
+	t.Cleanup(func() { _ = waitingUidGenerator.Shutdown(ctx) })
+	uid, err := defaultUidGenerator.GetUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second := uid>>defaultUidGenerator.bitsAllocator.TimestampShift + defaultUidGenerator.epochTimestamp; second <= future.Unix() {
+		t.Errorf("uid second %d is not after the last timestamp %d", second, future.Unix())
+	}
+}
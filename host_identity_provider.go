@@ -0,0 +1,126 @@
+package uidgenerator
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// ErrNoHostIdentity Returned by HostIdentityProvider when the environment doesn't provide the identity
+var ErrNoHostIdentity = errors.New("no host identity")
+
+/*
+HostIdentityProvider
+Represents where the host name and port of WORKER_NODE come from.
+Without a provider, the JPAAS environment is used if it's set, otherwise the node is identified as an actual machine.
+*/
+type HostIdentityProvider interface {
+	// HostIdentity Returns the host name and port identifying the node, ErrNoHostIdentity if the environment doesn't provide
+	HostIdentity() (hostName, port string, err error)
+	// NodeType The type of the worker nodes identified by the provider
+	NodeType() WorkerNodeType
+}
+
+// KubernetesHostIdentityProvider Identify the pod by POD_NAME and POD_IP exposed through the downward API:
+//
+//	env:
+//	- name: POD_NAME
+//	  valueFrom: {fieldRef: {fieldPath: metadata.name}}
+//	- name: POD_IP
+//	  valueFrom: {fieldRef: {fieldPath: status.podIP}}
+//
+// The pod name is the host name and the pod ip is the port
+type KubernetesHostIdentityProvider struct {
+	// PodNameEnv default as POD_NAME
+	PodNameEnv string
+	// PodIpEnv default as POD_IP
+	PodIpEnv string
+}
+
+func (k KubernetesHostIdentityProvider) HostIdentity() (string, string, error) {
+	podNameEnv, podIpEnv := k.PodNameEnv, k.PodIpEnv
+	if podNameEnv == "" {
+		podNameEnv = "POD_NAME"
+	}
+	if podIpEnv == "" {
+		podIpEnv = "POD_IP"
+	}
+	return hostIdentityFromEnv(podNameEnv, os.Getenv(podNameEnv), podIpEnv, os.Getenv(podIpEnv))
+}
+
+func (k KubernetesHostIdentityProvider) NodeType() WorkerNodeType {
+	return WorkerNodeTypeContainer
+}
+
+// HostnameHostIdentityProvider Identify the node by HOSTNAME, or the host name reported by the kernel, and the port it serves
+type HostnameHostIdentityProvider struct {
+	Port string
+}
+
+func (h HostnameHostIdentityProvider) HostIdentity() (string, string, error) {
+	hostName := os.Getenv("HOSTNAME")
+	if hostName == "" {
+		var err error
+		if hostName, err = os.Hostname(); err != nil {
+			return "", "", err
+		}
+	}
+	if h.Port == "" {
+		return "", "", errors.New("port is required to identify the host")
+	}
+	return hostName, h.Port, nil
+}
+
+func (h HostnameHostIdentityProvider) NodeType() WorkerNodeType {
+	return WorkerNodeTypeContainer
+}
+
+// JpaasHostIdentityProvider Identify the container by JPAAS_HOST and JPAAS_HTTP_PORT (or JPAAS_HOST_PORT_8080), as the Java project does
+type JpaasHostIdentityProvider struct {
+}
+
+func (j JpaasHostIdentityProvider) HostIdentity() (string, string, error) {
+	dockerInfo, err := retrieveFromEnv()
+	if err != nil {
+		return "", "", err
+	}
+	if !dockerInfo.IsDocker {
+		return "", "", fmt.Errorf("%w: %s and %s are not set", ErrNoHostIdentity, envKeyHost, envKeyPort)
+	}
+	return dockerInfo.Host, dockerInfo.Port, nil
+}
+
+func (j JpaasHostIdentityProvider) NodeType() WorkerNodeType {
+	return WorkerNodeTypeContainer
+}
+
+// ActualHostIdentityProvider Identify the actual machine by the local address, the port is the launch time with a random number
+type ActualHostIdentityProvider struct {
+}
+
+func (a ActualHostIdentityProvider) HostIdentity() (string, string, error) {
+	localAddress, err := LocalAddress()
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	r := rand.New(rand.NewSource(now.UnixNano()))
+	return localAddress, fmt.Sprintf("%d-%d", now.UnixMilli(), r.Intn(100000)), nil
+}
+
+func (a ActualHostIdentityProvider) NodeType() WorkerNodeType {
+	return WorkerNodeTypeActual
+}
+
+// hostIdentityFromEnv Both of the host and port must be set, or neither of them
+func hostIdentityFromEnv(hostKey, host, portKey, port string) (string, string, error) {
+	if host == "" && port == "" {
+		return "", "", fmt.Errorf("%w: %s and %s are not set", ErrNoHostIdentity, hostKey, portKey)
+	}
+	if host == "" || port == "" {
+		return "", "", fmt.Errorf("missing host or port from env, %s:%q, %s:%q", hostKey, host, portKey, port)
+	}
+	return host, port, nil
+}
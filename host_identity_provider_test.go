@@ -0,0 +1,52 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHostIdentityProvider(t *testing.T) {
+	t.Setenv("POD_NAME", "")
+	t.Setenv("POD_IP", "")
+	if _, _, err := (KubernetesHostIdentityProvider{}).HostIdentity(); !errors.Is(err, ErrNoHostIdentity) {
+		t.Errorf("err is %v, want ErrNoHostIdentity", err)
+	}
+	t.Setenv("POD_NAME", "web-5d8f7c-x2k9p")
+	if _, _, err := (KubernetesHostIdentityProvider{}).HostIdentity(); err == nil || errors.Is(err, ErrNoHostIdentity) {
+		t.Errorf("half set env should be refused, err is %v", err)
+	}
+	t.Setenv("POD_IP", "10.1.2.3")
+
+	assigner, err := NewSQLiteWorkerIdAssigner(filepath.Join(t.TempDir(), "uid_generator.db"),
+		WithDisposableHostIdentity(KubernetesHostIdentityProvider{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer assigner.db.Close()
+	workerLease, err := assigner.AssignWorkerId(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	workerNode, err := assigner.Registry().Get(context.Background(), workerLease.WorkerId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workerNode.HostName != "web-5d8f7c-x2k9p" || workerNode.Port != "10.1.2.3" || workerNode.Type != WorkerNodeTypeContainer {
+		t.Errorf("worker node is %+v, want the pod", workerNode)
+	}
+	// the times are written in UTC, whatever the local time zone is
+	if workerNode, err := buildWorkerNode(KubernetesHostIdentityProvider{}); err != nil || workerNode.Created.Location() != time.UTC {
+		t.Errorf("worker node is %+v, %v, want created in UTC", workerNode, err)
+	}
+
+	if _, _, err := (HostnameHostIdentityProvider{}).HostIdentity(); err == nil {
+		t.Error("host name without port should be refused")
+	}
+	t.Setenv("HOSTNAME", "uid-host")
+	if hostName, port, err := (HostnameHostIdentityProvider{Port: "8080"}).HostIdentity(); err != nil || hostName != "uid-host" || port != "8080" {
+		t.Errorf("identity is %s:%s %v, want uid-host:8080", hostName, port, err)
+	}
+}
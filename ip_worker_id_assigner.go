@@ -0,0 +1,114 @@
+package uidgenerator
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math/bits"
+	"net"
+)
+
+/*
+IpWorkerIdAssigner
+Represents an implementation of WorkerIdAssigner deriving the worker id from the host ip, like Leaf and classic Snowflake.
+
+worker id = (the low 64 bits of the ip & mask) + subnetOffset
+
+The low 32 bits of IPv4 or the interface identifier of IPv6 are masked, so no coordination is needed
+on a flat network as long as the masked bits are unique. The subnetOffset separates several subnets
+sharing the worker id space. The mask defaults to the low bits allowed by the worker bits.
+*/
+type IpWorkerIdAssigner struct {
+	ip           string
+	mask         uint64
+	subnetOffset int64
+	bounds       WorkerIdBounds
+}
+
+type OptionIp func(ipWorkerIdAssigner *IpWorkerIdAssigner)
+
+// WithIpAddress The ip to derive from, default as the local address
+func WithIpAddress(ip string) OptionIp {
+	return func(ipWorkerIdAssigner *IpWorkerIdAssigner) {
+		ipWorkerIdAssigner.ip = ip
+	}
+}
+
+// WithIpMask Mask of the ip bits, such as 0xffff for the hosts of a /16 subnet
+func WithIpMask(mask uint64) OptionIp {
+	return func(ipWorkerIdAssigner *IpWorkerIdAssigner) {
+		ipWorkerIdAssigner.mask = mask
+	}
+}
+
+// WithIpSubnetOffset Added to the masked ip bits, such as 1024 for the second /22 subnet
+func WithIpSubnetOffset(subnetOffset int64) OptionIp {
+	return func(ipWorkerIdAssigner *IpWorkerIdAssigner) {
+		ipWorkerIdAssigner.subnetOffset = subnetOffset
+	}
+}
+
+func NewIpWorkerIdAssigner(opts ...OptionIp) (*IpWorkerIdAssigner, error) {
+	assigner := IpWorkerIdAssigner{
+		bounds: WorkerIdBounds{MaxWorkerId: ^(-1 << defaultWorkerBits)},
+	}
+	for _, opt := range opts {
+		opt(&assigner)
+	}
+	if assigner.ip == "" {
+		localAddress, err := LocalAddress()
+		if err != nil {
+			return nil, err
+		}
+		assigner.ip = localAddress
+	}
+	if net.ParseIP(assigner.ip) == nil {
+		return nil, fmt.Errorf("invalid ip %q", assigner.ip)
+	}
+	if assigner.subnetOffset < 0 {
+		return nil, errors.New("subnet offset must not be negative")
+	}
+	return &assigner, nil
+}
+
+func (i *IpWorkerIdAssigner) SetWorkerIdBounds(bounds WorkerIdBounds) {
+	i.bounds = bounds
+}
+
+func (i *IpWorkerIdAssigner) AssignWorkerId(ctx context.Context) (WorkerLease, error) {
+	mask := i.mask
+	if mask == 0 {
+		mask = uint64(i.bounds.MaxWorkerId)
+	}
+	hostBits := ipLowBits(net.ParseIP(i.ip)) & mask
+	maxHostBits := mask >> bits.TrailingZeros64(mask)
+	workerId := int64(hostBits>>bits.TrailingZeros64(mask)) + i.subnetOffset
+	// the widest host of the mask overflows, some hosts of the subnet can't get a worker id
+	if widest := maxHostBits + uint64(i.subnetOffset); widest > uint64(i.bounds.MaxWorkerId) || widest < maxHostBits {
+		log.Printf("ip mask %#x covers %d bits, with subnet offset %d it may derive worker id up to %d, but the max is %d",
+			mask, bits.Len64(maxHostBits), i.subnetOffset, widest, i.bounds.MaxWorkerId)
+	}
+	if workerId < 0 || workerId > i.bounds.MaxWorkerId {
+		return nil, fmt.Errorf("worker id %d derived from ip %s (ip & mask %#x = %d, + subnet offset %d) exceeds the max %d, "+
+			"the mask needs %d bits but there are %d worker bits, narrow the mask or enlarge the worker bits, "+
+			"truncating would give the same worker id to hosts differing only in the high bits",
+			workerId, i.ip, mask, workerId-i.subnetOffset, i.subnetOffset, i.bounds.MaxWorkerId,
+			bits.Len64(maxHostBits+uint64(i.subnetOffset)), bits.Len64(uint64(i.bounds.MaxWorkerId)))
+	}
+	return NewWorkerLease(workerId), nil
+}
+
+// Release The ip is stable, there is nothing to give back
+func (i *IpWorkerIdAssigner) Release(ctx context.Context) error {
+	return nil
+}
+
+// ipLowBits The 32 bits of IPv4, or the low 64 bits of IPv6
+func ipLowBits(ip net.IP) uint64 {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return uint64(binary.BigEndian.Uint32(ipv4))
+	}
+	return binary.BigEndian.Uint64(ip.To16()[8:])
+}
@@ -0,0 +1,45 @@
+package uidgenerator
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIpWorkerIdAssigner(t *testing.T) {
+	tests := []struct {
+		ip           string
+		mask         uint64
+		subnetOffset int64
+		workerId     int64
+		wantErr      bool
+	}{
+		{ip: "10.0.3.7", workerId: 3<<8 | 7},
+		{ip: "10.0.3.7", mask: 0xff, workerId: 7},
+		{ip: "10.0.3.7", mask: 0xff00, workerId: 3},
+		{ip: "10.0.3.7", mask: 0xff, subnetOffset: 256, workerId: 263},
+		{ip: "fe80::1:2", mask: 0xffff, workerId: 2},
+		{ip: "10.0.9.7", mask: 0xffff, wantErr: true},
+		{ip: "10.0.3.7", mask: 0xff, subnetOffset: 1020, wantErr: true},
+	}
+	for _, test := range tests {
+		assigner, err := NewIpWorkerIdAssigner(WithIpAddress(test.ip), WithIpMask(test.mask), WithIpSubnetOffset(test.subnetOffset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assigner.SetWorkerIdBounds(WorkerIdBounds{MaxWorkerId: 1023})
+		workerLease, err := assigner.AssignWorkerId(context.Background())
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s & %#x + %d: want error, got worker id %d", test.ip, test.mask, test.subnetOffset, workerLease.WorkerId())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s & %#x + %d: %v", test.ip, test.mask, test.subnetOffset, err)
+			continue
+		}
+		if workerLease.WorkerId() != test.workerId {
+			t.Errorf("%s & %#x + %d: worker id is %d, want %d", test.ip, test.mask, test.subnetOffset, workerLease.WorkerId(), test.workerId)
+		}
+	}
+}
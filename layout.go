@@ -0,0 +1,138 @@
+package uidgenerator
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Names of the segments known by the generators, the other segments are fixed fields set by WithSegmentValue
+const (
+	SegmentTimestamp    = "timestamp"
+	SegmentDatacenterId = "datacenterId"
+	SegmentWorkerId     = "workerId"
+	SegmentSequence     = "sequence"
+	// SegmentVersion The layout version, increased by the epoch rollover, it must be above the timestamp
+	SegmentVersion = "version"
+)
+
+// LayoutSegment A named field of the uid, Shift is counted from the lowest bit
+type LayoutSegment struct {
+	Name  string
+	Bits  int
+	Shift int
+	Max   int64
+}
+
+// Allocate Place value into the segment, value must not exceed Max
+func (l LayoutSegment) Allocate(value int64) int64 {
+	return value << l.Shift
+}
+
+// Extract The value of the segment in uid
+func (l LayoutSegment) Extract(uid int64) int64 {
+	return (uid >> l.Shift) & l.Max
+}
+
+/*
+Layout
+The ordered segments of the uid from the highest bit, after the sign bit.
+For example, a uid with a 4 bits entity type and a 6 bits shard:
+
+	+------+-----------+-------------+-------+-----------+-----------+
+	| sign | timestamp | entity type | shard | worker id | sequence  |
+	+------+-----------+-------------+-------+-----------+-----------+
+	  1bit     30bits       4bits      6bits     10bits      13bits
+*/
+type Layout struct {
+	segments []LayoutSegment
+	index    map[string]int
+}
+
+// LayoutBuilder Build a Layout segment by segment, from the highest bit
+type LayoutBuilder struct {
+	segments []LayoutSegment
+}
+
+func NewLayoutBuilder() *LayoutBuilder {
+	return &LayoutBuilder{}
+}
+
+// Segment Append the segment of name below the segments appended before
+func (l *LayoutBuilder) Segment(name string, bits int) *LayoutBuilder {
+	l.segments = append(l.segments, LayoutSegment{Name: name, Bits: bits})
+	return l
+}
+
+/*
+Build
+The segments must fill the 63 bits after the sign bit, timestamp, workerId and sequence are required.
+The sequence must be the lowest segment, CachedUidGenerator fills the uids of a second by adding to the first one.
+*/
+func (l *LayoutBuilder) Build() (*Layout, error) {
+	layout := Layout{
+		segments: make([]LayoutSegment, len(l.segments)),
+		index:    make(map[string]int, len(l.segments)),
+	}
+	shift := totalBits - signBits
+	for i, segment := range l.segments {
+		if segment.Name == "" {
+			return nil, errors.New("segment name is required")
+		}
+		if _, ok := layout.index[segment.Name]; ok {
+			return nil, fmt.Errorf("duplicate segment %s", segment.Name)
+		}
+		if segment.Bits <= 0 || segment.Bits > shift {
+			return nil, fmt.Errorf("segment %s has %d bits, only %d bits left", segment.Name, segment.Bits, shift)
+		}
+		shift -= segment.Bits
+		segment.Shift = shift
+		segment.Max = ^(-1 << segment.Bits)
+		layout.segments[i] = segment
+		layout.index[segment.Name] = i
+	}
+	if shift != 0 {
+		return nil, fmt.Errorf("allocate not enough 64 bits, %d bits left", shift)
+	}
+	for _, name := range []string{SegmentTimestamp, SegmentWorkerId, SegmentSequence} {
+		if _, ok := layout.index[name]; !ok {
+			return nil, fmt.Errorf("segment %s is required", name)
+		}
+	}
+	if layout.segments[len(layout.segments)-1].Name != SegmentSequence {
+		return nil, errors.New("segment sequence must be the lowest")
+	}
+	return &layout, nil
+}
+
+// Segments The segments from the highest bit
+func (l *Layout) Segments() []LayoutSegment {
+	return append([]LayoutSegment(nil), l.segments...)
+}
+
+// Segment The segment of name, ok is false if there is none
+func (l *Layout) Segment(name string) (LayoutSegment, bool) {
+	i, ok := l.index[name]
+	if !ok {
+		return LayoutSegment{}, false
+	}
+	return l.segments[i], true
+}
+
+// defaultLayout The layout of WithBits, WithDatacenter and WithEpochRollover: sign -> version -> timestamp -> datacenterId -> workerId -> sequence
+func defaultLayout(versionBits, timestampBits, datacenterIdBits, workerIdBits, sequenceBits int) (*Layout, error) {
+	if timestampBits < 0 || datacenterIdBits < 0 || workerIdBits < 0 || sequenceBits < 0 {
+		return nil, errors.New("bits must not be negative")
+	}
+	if signBits+versionBits+timestampBits+datacenterIdBits+workerIdBits+sequenceBits != totalBits {
+		return nil, errors.New("allocate not enough 64 bits")
+	}
+	builder := NewLayoutBuilder()
+	if versionBits > 0 {
+		builder.Segment(SegmentVersion, versionBits)
+	}
+	builder.Segment(SegmentTimestamp, timestampBits)
+	if datacenterIdBits > 0 {
+		builder.Segment(SegmentDatacenterId, datacenterIdBits)
+	}
+	return builder.Segment(SegmentWorkerId, workerIdBits).Segment(SegmentSequence, sequenceBits).Build()
+}
@@ -0,0 +1,72 @@
+package uidgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestLayout(t *testing.T) {
+	layout, err := NewLayoutBuilder().
+		Segment(SegmentTimestamp, 30).
+		Segment("entityType", 4).
+		Segment("shard", 6).
+		Segment(SegmentWorkerId, 10).
+		Segment(SegmentSequence, 13).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultUidGenerator, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{workerId: 42}, WithLayout(layout),
+		WithSegmentValue("entityType", 9), WithSegmentValue("shard", 33))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedUidGenerator, err := NewCachedUidGenerator(defaultUidGenerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cachedUidGenerator.Shutdown(context.Background())
+
+	entityType, _ := layout.Segment("entityType")
+	shard, _ := layout.Segment("shard")
+	workerId, _ := layout.Segment(SegmentWorkerId)
+	for _, uidGenerator := range []UidGenerator{defaultUidGenerator, cachedUidGenerator} {
+		uid, err := uidGenerator.GetUID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entityType.Extract(uid) != 9 || shard.Extract(uid) != 33 || workerId.Extract(uid) != 42 {
+			t.Errorf("segments of %d are %d, %d and %d, want 9, 33 and 42", uid, entityType.Extract(uid), shard.Extract(uid), workerId.Extract(uid))
+		}
+		var parsed map[string]string
+		if err := json.Unmarshal([]byte(uidGenerator.ParseUID(uid)), &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if parsed["entityType"] != "9" || parsed["shard"] != "33" || parsed["workerId"] != "42" {
+			t.Errorf("parsed %v, want entityType 9, shard 33 and workerId 42", parsed)
+		}
+	}
+
+	if _, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithLayout(layout), WithSegmentValue("shard", 64)); err == nil {
+		t.Error("shard exceeds the max should be refused")
+	}
+	if _, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithLayout(layout), WithSegmentValue("region", 1)); err == nil {
+		t.Error("segment not in the layout should be refused")
+	}
+}
+
+func TestLayoutBuild(t *testing.T) {
+	builders := map[string]*LayoutBuilder{
+		"not 63 bits": NewLayoutBuilder().Segment(SegmentTimestamp, 28).Segment(SegmentWorkerId, 22).Segment(SegmentSequence, 12),
+		"duplicate":   NewLayoutBuilder().Segment(SegmentTimestamp, 28).Segment(SegmentWorkerId, 11).Segment(SegmentWorkerId, 11).Segment(SegmentSequence, 13),
+		"no worker":   NewLayoutBuilder().Segment(SegmentTimestamp, 28).Segment("shard", 22).Segment(SegmentSequence, 13),
+		"sequence":    NewLayoutBuilder().Segment(SegmentTimestamp, 28).Segment(SegmentSequence, 13).Segment(SegmentWorkerId, 22),
+		"zero bits":   NewLayoutBuilder().Segment(SegmentTimestamp, 28).Segment("shard", 0).Segment(SegmentWorkerId, 22).Segment(SegmentSequence, 13),
+	}
+	for name, builder := range builders {
+		if _, err := builder.Build(); err == nil {
+			t.Errorf("%s: the layout should be refused", name)
+		}
+	}
+}
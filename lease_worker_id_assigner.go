@@ -0,0 +1,366 @@
+package uidgenerator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultLeaseTTL          = 30 * time.Second
+	defaultLeaseSafetyMargin = 10 * time.Second
+	// leaseAssignRetries Retries when other nodes race for the same worker id
+	leaseAssignRetries = 8
+)
+
+// errLeaseRace Another node took over or inserted the worker id first
+var errLeaseRace = errors.New("another node took the worker id first")
+
+/*
+LeaseWorkerIdAssigner
+Represents an implementation of WorkerIdAssigner reusing worker ids from a fixed pool in WORKER_NODE.
+
+Unlike DisposableWorkerIdAssigner, which burns one worker id per launch, each row of the pool is leased
+to one node, the node renews the lease by writing the MODIFIED heartbeat column every heartbeatInterval.
+A worker id can be taken over only after its MODIFIED is older than ttl + safetyMargin, the margin
+covers the clock skew between nodes. If the lease can't be renewed within ttl, the generator is fenced.
+
+The worker ids are chosen in [minWorkerId, maxWorkerId], minWorkerId defaults to 1 since MySQL
+treats an explicit 0 as a request to generate the AUTO_INCREMENT value.
+Don't share the table with DisposableWorkerIdAssigner, the pool rows are written with explicit ids.
+
+It's a LastTimestampKeeper, the last timestamp of each worker id is kept in the LAST_TIMESTAMP column as ms.
+With datacenter bits, the ID of a worker id is datacenterId << machine bits | workerId, the worker field of the uid.
+*/
+type LeaseWorkerIdAssigner struct {
+	db      *sql.DB
+	dialect Dialect
+	table   WorkerNodeTable
+	// provider identifies the worker node, nil means JPAAS or the actual machine
+	provider HostIdentityProvider
+
+	ttl               time.Duration
+	safetyMargin      time.Duration
+	heartbeatInterval time.Duration
+	workerIdRange
+
+	workerNode *WorkerNode
+	lease      *renewableWorkerLease
+	stop       chan struct{}
+
+	selectExpiredSql string
+	takeOverSql      string
+	selectMaxIdSql   string
+	insertSql        string
+	heartbeatSql     string
+	selectLastSql    string
+	saveLastSql      string
+}
+
+type OptionLease func(leaseWorkerIdAssigner *LeaseWorkerIdAssigner)
+
+// WithLeaseTTL The lease is lost if it isn't renewed within ttl, default as 30s
+func WithLeaseTTL(ttl time.Duration) OptionLease {
+	return func(leaseWorkerIdAssigner *LeaseWorkerIdAssigner) {
+		leaseWorkerIdAssigner.ttl = ttl
+	}
+}
+
+// WithLeaseSafetyMargin Extra time to wait after ttl before taking over an expired worker id, default as 10s
+func WithLeaseSafetyMargin(safetyMargin time.Duration) OptionLease {
+	return func(leaseWorkerIdAssigner *LeaseWorkerIdAssigner) {
+		leaseWorkerIdAssigner.safetyMargin = safetyMargin
+	}
+}
+
+// WithLeaseHeartbeatInterval Interval to renew the lease, default as ttl/3, at least 1s
+func WithLeaseHeartbeatInterval(heartbeatInterval time.Duration) OptionLease {
+	return func(leaseWorkerIdAssigner *LeaseWorkerIdAssigner) {
+		leaseWorkerIdAssigner.heartbeatInterval = heartbeatInterval
+	}
+}
+
+// WithLeaseRange Lease worker id in [minWorkerId, maxWorkerId] only, default as [1, MaxWorkerId]
+func WithLeaseRange(minWorkerId, maxWorkerId int64) OptionLease {
+	return func(leaseWorkerIdAssigner *LeaseWorkerIdAssigner) {
+		leaseWorkerIdAssigner.setRange(minWorkerId, maxWorkerId)
+	}
+}
+
+// WithLeaseTable Names of the worker node table and its columns, default as DefaultWorkerNodeTable
+func WithLeaseTable(table WorkerNodeTable) OptionLease {
+	return func(leaseWorkerIdAssigner *LeaseWorkerIdAssigner) {
+		leaseWorkerIdAssigner.table = table
+	}
+}
+
+// WithLeaseHostIdentity Where the host name and port of the worker node come from, default as JPAAS or the actual machine
+func WithLeaseHostIdentity(provider HostIdentityProvider) OptionLease {
+	return func(leaseWorkerIdAssigner *LeaseWorkerIdAssigner) {
+		leaseWorkerIdAssigner.provider = provider
+	}
+}
+
+// NewLeaseWorkerIdAssigner Constructor with an opened db and the dialect of it, see EnsureSchema to create the table
+func NewLeaseWorkerIdAssigner(db *sql.DB, dialect Dialect, opts ...OptionLease) (*LeaseWorkerIdAssigner, error) {
+	assigner := LeaseWorkerIdAssigner{
+		db:            db,
+		dialect:       dialect,
+		ttl:           defaultLeaseTTL,
+		safetyMargin:  defaultLeaseSafetyMargin,
+		workerIdRange: newWorkerIdRange(1),
+		table:         DefaultWorkerNodeTable(),
+	}
+	for _, opt := range opts {
+		opt(&assigner)
+	}
+	assigner.selectExpiredSql = dialect.Rebind(assigner.table.render(`
+SELECT ID FROM WORKER_NODE
+WHERE ID BETWEEN ? AND ? AND MODIFIED < ?
+ORDER BY MODIFIED
+LIMIT 1
+`))
+	assigner.takeOverSql = dialect.Rebind(assigner.table.render(`
+UPDATE WORKER_NODE
+SET HOST_NAME = ?, PORT = ?, TYPE = ?, LAUNCH_DATE = ?, MODIFIED = ?
+WHERE ID = ? AND MODIFIED < ?
+`))
+	assigner.selectMaxIdSql = dialect.Rebind(assigner.table.render(`
+SELECT MAX(ID) FROM WORKER_NODE WHERE ID BETWEEN ? AND ?
+`))
+	assigner.insertSql = dialect.Rebind(assigner.table.render(`
+INSERT INTO WORKER_NODE
+    (ID,HOST_NAME,PORT,TYPE,LAUNCH_DATE,MODIFIED,CREATED)
+VALUES
+    (?,?,?,?,?,?,?)
+`))
+	assigner.heartbeatSql = dialect.Rebind(assigner.table.render(`
+UPDATE WORKER_NODE SET MODIFIED = ? WHERE ID = ? AND HOST_NAME = ? AND PORT = ?
+`))
+	assigner.selectLastSql = dialect.Rebind(assigner.table.render(`
+SELECT LAST_TIMESTAMP FROM WORKER_NODE WHERE ID = ?
+`))
+	assigner.saveLastSql = dialect.Rebind(assigner.table.render(`
+UPDATE WORKER_NODE SET LAST_TIMESTAMP = ? WHERE ID = ? AND LAST_TIMESTAMP < ?
+`))
+	if assigner.heartbeatInterval == 0 {
+		assigner.heartbeatInterval = assigner.ttl / 3
+	}
+	// MySQL reports no affected rows if MODIFIED is renewed to the same second
+	if assigner.heartbeatInterval < time.Second || assigner.heartbeatInterval >= assigner.ttl {
+		return nil, fmt.Errorf("heartbeat interval %v must be in [1s, ttl %v)", assigner.heartbeatInterval, assigner.ttl)
+	}
+	if assigner.safetyMargin < 0 {
+		return nil, errors.New("safety margin must not be negative")
+	}
+	if assigner.minWorkerId < 1 || assigner.maxWorkerId < 0 {
+		return nil, errors.New("lease range must be positive")
+	}
+	if err := assigner.checkRange(); err != nil {
+		return nil, err
+	}
+	return &assigner, nil
+}
+
+// EnsureSchema Create or migrate WORKER_NODE by the migrations of the dialect
+func (l *LeaseWorkerIdAssigner) EnsureSchema(ctx context.Context) error {
+	return ensureWorkerNodeSchema(ctx, l.db, l.dialect, l.table)
+}
+
+// Registry The worker nodes leased by the assigners
+func (l *LeaseWorkerIdAssigner) Registry() *WorkerNodeRegistry {
+	return NewWorkerNodeRegistry(l.db, l.dialect, WithRegistryTable(l.table))
+}
+
+/*
+AssignWorkerId
+Take over the worker id expired for the longest time, if there is none, extend the pool with a new row.
+*/
+func (l *LeaseWorkerIdAssigner) AssignWorkerId(ctx context.Context) (WorkerLease, error) {
+	if l.lease != nil {
+		return nil, fmt.Errorf("worker id %d is leased already", l.lease.WorkerId())
+	}
+	maxWorkerId, err := l.rangeMax()
+	if err != nil {
+		return nil, err
+	}
+	workerNode, err := buildWorkerNode(l.provider)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for i := 0; i < leaseAssignRetries; i++ {
+		now := time.Now().UTC()
+		workerId, err := l.takeOver(ctx, workerNode, l.rowId(l.minWorkerId), l.rowId(maxWorkerId), now)
+		if err == nil && workerId == 0 {
+			workerId, err = l.extend(ctx, workerNode, l.rowId(l.minWorkerId), l.rowId(maxWorkerId), now)
+		}
+		if err != nil {
+			if errors.Is(err, ErrNoWorkerIdAvailable) || ctx.Err() != nil {
+				return nil, err
+			}
+			lastErr = err
+			continue
+		}
+		return l.start(workerId-l.rowId(0), workerNode, now), nil
+	}
+	return nil, fmt.Errorf("lease worker id: %w", lastErr)
+}
+
+// ClaimWorkerId Claim the specified worker id, returns ErrWorkerIdConflict if it's leased by an alive node
+func (l *LeaseWorkerIdAssigner) ClaimWorkerId(ctx context.Context, workerId int64) (WorkerLease, error) {
+	if l.lease != nil {
+		return nil, fmt.Errorf("worker id %d is leased already", l.lease.WorkerId())
+	}
+	workerNode, err := buildWorkerNode(l.provider)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	takenOver, err := l.takeOverId(ctx, workerNode, l.rowId(workerId), now)
+	if err != nil {
+		return nil, err
+	}
+	if !takenOver {
+		if err := l.insertId(ctx, workerNode, l.rowId(workerId), now); err != nil {
+			return nil, fmt.Errorf("%w: worker id %d is leased by an alive node: %v", ErrWorkerIdConflict, workerId, err)
+		}
+	}
+	return l.start(workerId, workerNode, now), nil
+}
+
+// start Hold the lease of workerId leased at now, and renew it in the background
+func (l *LeaseWorkerIdAssigner) start(workerId int64, workerNode *WorkerNode, now time.Time) WorkerLease {
+	l.workerNode = workerNode
+	l.lease = newRenewableWorkerLease(workerId, now.Add(l.ttl))
+	l.stop = make(chan struct{})
+	go keepWorkerLease(l.lease, l.heartbeatInterval, l.ttl, l.stop, func(ctx context.Context, now time.Time) error {
+		return l.heartbeat(ctx, l.rowId(workerId), workerNode, now)
+	})
+	return l.lease
+}
+
+// rowId The ID of workerId in WORKER_NODE, the worker ids of each datacenter are kept apart as datacenterId << machine bits | workerId
+func (l *LeaseWorkerIdAssigner) rowId(workerId int64) int64 {
+	if !l.bounds.Datacenter {
+		return workerId
+	}
+	return l.bounds.DatacenterId*(l.bounds.MaxWorkerId+1) + workerId
+}
+
+// takeOver Returns the row id taken over in [minRowId, maxRowId], 0 if there is no expired worker id
+func (l *LeaseWorkerIdAssigner) takeOver(ctx context.Context, workerNode *WorkerNode, minRowId, maxRowId int64, now time.Time) (int64, error) {
+	var workerId int64
+	err := l.db.QueryRowContext(ctx, l.selectExpiredSql, minRowId, maxRowId, now.Add(-l.ttl-l.safetyMargin)).Scan(&workerId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	takenOver, err := l.takeOverId(ctx, workerNode, workerId, now)
+	if err != nil {
+		return 0, err
+	}
+	if !takenOver {
+		return 0, errLeaseRace
+	}
+	return workerId, nil
+}
+
+// takeOverId Take over workerId if it exists and expired
+func (l *LeaseWorkerIdAssigner) takeOverId(ctx context.Context, workerNode *WorkerNode, workerId int64, now time.Time) (bool, error) {
+	result, err := l.db.ExecContext(ctx, l.takeOverSql,
+		workerNode.HostName, workerNode.Port, workerNode.Type, workerNode.LaunchDate, now, workerId, now.Add(-l.ttl-l.safetyMargin))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// extend Insert the next row id of the pool in [minRowId, maxRowId], returns ErrNoWorkerIdAvailable if the pool is full
+func (l *LeaseWorkerIdAssigner) extend(ctx context.Context, workerNode *WorkerNode, minRowId, maxRowId int64, now time.Time) (int64, error) {
+	var lastId sql.NullInt64
+	if err := l.db.QueryRowContext(ctx, l.selectMaxIdSql, minRowId, maxRowId).Scan(&lastId); err != nil {
+		return 0, err
+	}
+	workerId := minRowId
+	if lastId.Valid {
+		workerId = lastId.Int64 + 1
+	}
+	if workerId > maxRowId {
+		return 0, fmt.Errorf("%w: all worker ids [%d, %d] are leased by alive nodes", ErrNoWorkerIdAvailable, minRowId-l.rowId(0), maxRowId-l.rowId(0))
+	}
+	if err := l.insertId(ctx, workerNode, workerId, now); err != nil {
+		// mostly a duplicate key, another node inserted it first
+		return 0, fmt.Errorf("%w: %v", errLeaseRace, err)
+	}
+	return workerId, nil
+}
+
+func (l *LeaseWorkerIdAssigner) insertId(ctx context.Context, workerNode *WorkerNode, workerId int64, now time.Time) error {
+	_, err := l.db.ExecContext(ctx, l.insertSql,
+		workerId, workerNode.HostName, workerNode.Port, workerNode.Type, workerNode.LaunchDate, now, now)
+	return err
+}
+
+// heartbeat Renew MODIFIED of the worker id, only if the row is still owned by workerNode
+func (l *LeaseWorkerIdAssigner) heartbeat(ctx context.Context, workerId int64, workerNode *WorkerNode, now time.Time) error {
+	result, err := l.db.ExecContext(ctx, l.heartbeatSql, now.UTC(), workerId, workerNode.HostName, workerNode.Port)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errWorkerLeaseTaken
+	}
+	return nil
+}
+
+/*
+Release
+Stop the heartbeat and backdate MODIFIED by ttl, so the worker id can be taken over after the safety margin.
+*/
+func (l *LeaseWorkerIdAssigner) Release(ctx context.Context) error {
+	if l.lease == nil {
+		return nil
+	}
+	close(l.stop)
+	l.lease.lose(errors.New("released"))
+	err := l.heartbeat(ctx, l.rowId(l.lease.WorkerId()), l.workerNode, time.Now().Add(-l.ttl))
+	l.lease = nil
+	if errors.Is(err, errWorkerLeaseTaken) {
+		return nil
+	}
+	return err
+}
+
+func (l *LeaseWorkerIdAssigner) LoadLastTimestamp(ctx context.Context, workerId int64) (time.Time, error) {
+	var lastTimestamp sql.NullInt64
+	err := l.db.QueryRowContext(ctx, l.selectLastSql, l.rowId(workerId)).Scan(&lastTimestamp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	// a column added by hand may be NULL, the worker id is never used as 0
+	if !lastTimestamp.Valid || lastTimestamp.Int64 == 0 {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(lastTimestamp.Int64), nil
+}
+
+func (l *LeaseWorkerIdAssigner) SaveLastTimestamp(ctx context.Context, workerId int64, lastTimestamp time.Time) error {
+	_, err := l.db.ExecContext(ctx, l.saveLastSql, lastTimestamp.UnixMilli(), l.rowId(workerId), lastTimestamp.UnixMilli())
+	return err
+}
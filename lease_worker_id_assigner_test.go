@@ -0,0 +1,131 @@
+package uidgenerator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openSQLiteWorkerNode(t *testing.T) *sql.DB {
+	db, err := sql.Open(SQLiteDialect{}.DriverName(), "file:"+filepath.Join(t.TempDir(), "uid_generator.db")+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := ensureWorkerNodeSchema(context.Background(), db, SQLiteDialect{}, DefaultWorkerNodeTable()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestLeaseWorkerIdAssigner(t *testing.T) {
+	db := openSQLiteWorkerNode(t)
+	ctx := context.Background()
+	newAssigner := func(maxWorkerId int64) *LeaseWorkerIdAssigner {
+		assigner, err := NewLeaseWorkerIdAssigner(db, SQLiteDialect{},
+			WithLeaseTTL(3*time.Second), WithLeaseSafetyMargin(time.Second), WithLeaseHeartbeatInterval(time.Second), WithLeaseRange(1, maxWorkerId))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return assigner
+	}
+
+	first, second, third := newAssigner(2), newAssigner(2), newAssigner(2)
+	firstLease, err := first.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondLease, err := second.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if firstLease.WorkerId() != 1 || secondLease.WorkerId() != 2 {
+		t.Fatalf("worker ids are %d and %d, want 1 and 2", firstLease.WorkerId(), secondLease.WorkerId())
+	}
+	if _, err := third.AssignWorkerId(ctx); !errors.Is(err, ErrNoWorkerIdAvailable) {
+		t.Fatalf("err is %v, want ErrNoWorkerIdAvailable", err)
+	}
+
+	// the released worker id is reused after the safety margin, instead of burning a new one
+	if err := first.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	thirdLease, err := third.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thirdLease.WorkerId() != 1 {
+		t.Errorf("worker id is %d, want the released 1", thirdLease.WorkerId())
+	}
+
+	// the generator is fenced once the lease is lost
+	defaultUidGenerator, err := NewDefaultUidGenerator(newAssigner(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := defaultUidGenerator.GetUID(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE WORKER_NODE SET HOST_NAME = 'stolen' WHERE ID = 3"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-defaultUidGenerator.workerLease.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatal("lease is not lost after the worker id is taken")
+	}
+	if _, err := defaultUidGenerator.GetUID(); !errors.Is(err, ErrWorkerLeaseLost) {
+		t.Errorf("err is %v, want ErrWorkerLeaseLost", err)
+	}
+}
+
+func TestLeaseWorkerIdRange(t *testing.T) {
+	db := openSQLiteWorkerNode(t)
+	ctx := context.Background()
+	// [2, 2] is the range of worker id 2 only
+	first, err := NewLeaseWorkerIdAssigner(db, SQLiteDialect{}, WithLeaseRange(2, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workerLease, err := first.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = first.Release(ctx) })
+	if workerLease.WorkerId() != 2 {
+		t.Errorf("worker id is %d, want 2", workerLease.WorkerId())
+	}
+	second, err := NewLeaseWorkerIdAssigner(db, SQLiteDialect{}, WithLeaseRange(2, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.AssignWorkerId(ctx); !errors.Is(err, ErrNoWorkerIdAvailable) {
+		t.Errorf("err is %v, want ErrNoWorkerIdAvailable", err)
+	}
+
+	// the empty range is refused on construction
+	if _, err := NewLeaseWorkerIdAssigner(db, SQLiteDialect{}, WithLeaseRange(3, 1)); err == nil {
+		t.Error("lease range [3, 1] should be refused")
+	}
+}
+
+func TestLeaseLoadLastTimestampError(t *testing.T) {
+	db := openSQLiteWorkerNode(t)
+	assigner, err := NewLeaseWorkerIdAssigner(db, SQLiteDialect{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if lastTimestamp, err := assigner.LoadLastTimestamp(ctx, 1); err != nil || !lastTimestamp.IsZero() {
+		t.Fatalf("last timestamp of the unused worker id is %v, %v, want zero", lastTimestamp, err)
+	}
+	// a failed query must not look like a worker id never used
+	_ = db.Close()
+	if _, err := assigner.LoadLastTimestamp(ctx, 1); err == nil {
+		t.Error("the error of the query is swallowed")
+	}
+}
@@ -1,27 +1,58 @@
 package uidgenerator
 
 import (
+	"errors"
 	"net"
 	"sync"
 )
 
 var netInfo netUtils
 
+// netUtils Detect the local address lazily on the first use, an import never fails in a sandbox without network
 type netUtils struct {
-	LocalAddress string
+	mutex        sync.Mutex
+	detector     func() (string, error)
+	detected     bool
+	localAddress string
 }
 
-func init() {
-	once := sync.Once{}
-	once.Do(func() {
-		getLocalInetAddress()
-	})
+// LocalAddress Returns the first validated address of the non loopback interfaces.
+// It's cached once detected, a failed detection is retried on the next call, such as the network is up later
+func LocalAddress() (string, error) {
+	return netInfo.LocalAddress()
 }
 
-func getLocalInetAddress() {
+// SetLocalAddressDetector Override how the local address is detected, such as a fixed address from the configuration.
+// The address detected before is discarded
+func SetLocalAddressDetector(detector func() (string, error)) {
+	netInfo.mutex.Lock()
+	defer netInfo.mutex.Unlock()
+	netInfo.detector = detector
+	netInfo.detected = false
+}
+
+func (n *netUtils) LocalAddress() (string, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.detected {
+		return n.localAddress, nil
+	}
+	detector := n.detector
+	if detector == nil {
+		detector = getLocalInetAddress
+	}
+	localAddress, err := detector()
+	if err != nil {
+		return "", err
+	}
+	n.localAddress, n.detected = localAddress, true
+	return localAddress, nil
+}
+
+func getLocalInetAddress() (string, error) {
 	interfaces, err := net.Interfaces()
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 	for _, iface := range interfaces {
 		if iface.Flags&net.FlagLoopback != 0 {
@@ -29,7 +60,7 @@ func getLocalInetAddress() {
 		}
 		addresses, err := iface.Addrs()
 		if err != nil {
-			panic(err)
+			return "", err
 		}
 		for _, addr := range addresses {
 			ipNet, ok := addr.(*net.IPNet)
@@ -40,9 +71,8 @@ func getLocalInetAddress() {
 			if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
 				continue
 			}
-			netInfo.LocalAddress = ip.String()
-			return
+			return ip.String(), nil
 		}
 	}
-	panic("No validated local address")
+	return "", errors.New("no validated local address")
 }
@@ -0,0 +1,57 @@
+package uidgenerator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLocalAddressDetector(t *testing.T) {
+	t.Cleanup(func() { SetLocalAddressDetector(nil) })
+	detectErr := errors.New("no network in sandbox")
+	SetLocalAddressDetector(func() (string, error) {
+		return "", detectErr
+	})
+	if _, _, err := (ActualHostIdentityProvider{}).HostIdentity(); !errors.Is(err, detectErr) {
+		t.Errorf("err is %v, want the detector error", err)
+	}
+	if _, err := NewIpWorkerIdAssigner(); !errors.Is(err, detectErr) {
+		t.Errorf("err is %v, want the detector error", err)
+	}
+	// the failure is not cached, the detection is retried once the network is up
+	var detected int
+	SetLocalAddressDetector(func() (string, error) {
+		if detected++; detected == 1 {
+			return "", detectErr
+		}
+		return "10.0.0.9", nil
+	})
+	if _, err := LocalAddress(); !errors.Is(err, detectErr) {
+		t.Errorf("err is %v, want the detector error", err)
+	}
+	for i := 0; i < 2; i++ {
+		if localAddress, err := LocalAddress(); err != nil || localAddress != "10.0.0.9" {
+			t.Errorf("local address is %s, %v, want 10.0.0.9", localAddress, err)
+		}
+	}
+	if detected != 2 {
+		t.Errorf("detected %d times, want the success cached", detected)
+	}
+
+	SetLocalAddressDetector(func() (string, error) {
+		return "10.0.0.8", nil
+	})
+	assigner, err := NewIpWorkerIdAssigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assigner.ip != "10.0.0.8" {
+		t.Errorf("ip is %s, want the overridden 10.0.0.8", assigner.ip)
+	}
+
+	t.Setenv(envKeyHost, "uid-host")
+	t.Setenv(envKeyPort, "")
+	t.Setenv(envKeyPortOriginal, "")
+	if _, err := buildWorkerNode(nil); err == nil {
+		t.Error("half set JPAAS env should be refused")
+	}
+}
@@ -0,0 +1,247 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRedisKeyPrefix = "uid:worker:"
+	defaultRedisLeaseTTL  = 30 * time.Second
+)
+
+var (
+	// redisRenewScript Push the expiry forward only if the worker id is still owned by ARGV[1]
+	redisRenewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+	// redisReleaseScript Delete the key only if the worker id is still owned by ARGV[1]
+	redisReleaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+	// redisSaveLastScript Keep the max of the saved and ARGV[1]
+	redisSaveLastScript = redis.NewScript(`
+local saved = tonumber(redis.call("GET", KEYS[1]) or "0")
+if tonumber(ARGV[1]) > saved then
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+return 1
+`)
+)
+
+/*
+RedisWorkerIdAssigner
+Represents an implementation of WorkerIdAssigner leasing worker id from Redis.
+
+Each worker id in [minWorkerId, maxWorkerId] is a key prefix<id>, a node claims the first free one
+by SET NX PX with its own token as value, and renews the expiry every renewInterval in the background.
+If the lease can't be renewed within ttl, or the key is owned by another token, the generator is fenced.
+The local deadline is ttl minus a margin after the command is sent, before the key may expire on the server,
+with room for the clock drift between the node and Redis.
+
+It's a LastTimestampKeeper, the last timestamp of each worker id is kept in the persistent key prefix<id>:last as ms.
+*/
+type RedisWorkerIdAssigner struct {
+	client redis.UniversalClient
+	// provider identifies the node in the token, nil means JPAAS or the actual machine
+	provider HostIdentityProvider
+
+	keyPrefix     string
+	ttl           time.Duration
+	renewInterval time.Duration
+	// deadlineMargin The local deadline is ttl - deadlineMargin after sending the command
+	deadlineMargin time.Duration
+	workerIdRange
+
+	token string
+	lease *renewableWorkerLease
+	stop  chan struct{}
+}
+
+type OptionRedis func(redisWorkerIdAssigner *RedisWorkerIdAssigner)
+
+// WithRedisKeyPrefix Prefix of the worker id keys, default as uid:worker:
+func WithRedisKeyPrefix(keyPrefix string) OptionRedis {
+	return func(redisWorkerIdAssigner *RedisWorkerIdAssigner) {
+		redisWorkerIdAssigner.keyPrefix = keyPrefix
+	}
+}
+
+// WithRedisLeaseTTL The lease is lost if it isn't renewed within ttl, default as 30s
+func WithRedisLeaseTTL(ttl time.Duration) OptionRedis {
+	return func(redisWorkerIdAssigner *RedisWorkerIdAssigner) {
+		redisWorkerIdAssigner.ttl = ttl
+	}
+}
+
+// WithRedisRenewInterval Interval to renew the lease, default as ttl/3
+func WithRedisRenewInterval(renewInterval time.Duration) OptionRedis {
+	return func(redisWorkerIdAssigner *RedisWorkerIdAssigner) {
+		redisWorkerIdAssigner.renewInterval = renewInterval
+	}
+}
+
+// WithRedisDeadlineMargin The generator is fenced margin ahead of the key expiry, default as ttl/10
+func WithRedisDeadlineMargin(margin time.Duration) OptionRedis {
+	return func(redisWorkerIdAssigner *RedisWorkerIdAssigner) {
+		redisWorkerIdAssigner.deadlineMargin = margin
+	}
+}
+
+// WithRedisRange Claim worker id in [minWorkerId, maxWorkerId] only, default as [0, MaxWorkerId]
+func WithRedisRange(minWorkerId, maxWorkerId int64) OptionRedis {
+	return func(redisWorkerIdAssigner *RedisWorkerIdAssigner) {
+		redisWorkerIdAssigner.setRange(minWorkerId, maxWorkerId)
+	}
+}
+
+// WithRedisHostIdentity Where the host name and port of the token come from, default as JPAAS or the actual machine
+func WithRedisHostIdentity(provider HostIdentityProvider) OptionRedis {
+	return func(redisWorkerIdAssigner *RedisWorkerIdAssigner) {
+		redisWorkerIdAssigner.provider = provider
+	}
+}
+
+// NewRedisWorkerIdAssigner Constructor with a redis client, such as redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+func NewRedisWorkerIdAssigner(client redis.UniversalClient, opts ...OptionRedis) (*RedisWorkerIdAssigner, error) {
+	assigner := RedisWorkerIdAssigner{
+		client:        client,
+		keyPrefix:     defaultRedisKeyPrefix,
+		ttl:           defaultRedisLeaseTTL,
+		workerIdRange: newWorkerIdRange(0),
+
+		deadlineMargin: -1,
+	}
+	for _, opt := range opts {
+		opt(&assigner)
+	}
+	if assigner.renewInterval == 0 {
+		assigner.renewInterval = assigner.ttl / 3
+	}
+	if assigner.deadlineMargin < 0 {
+		assigner.deadlineMargin = assigner.ttl / 10
+	}
+	if assigner.renewInterval <= 0 || assigner.renewInterval+assigner.deadlineMargin >= assigner.ttl {
+		return nil, fmt.Errorf("renew interval %v plus deadline margin %v must be in (0, ttl %v)", assigner.renewInterval, assigner.deadlineMargin, assigner.ttl)
+	}
+	if assigner.minWorkerId < 0 || assigner.maxWorkerId < 0 {
+		return nil, errors.New("worker id range must not be negative")
+	}
+	if err := assigner.checkRange(); err != nil {
+		return nil, err
+	}
+	return &assigner, nil
+}
+
+func (r *RedisWorkerIdAssigner) AssignWorkerId(ctx context.Context) (WorkerLease, error) {
+	if r.lease != nil {
+		return nil, fmt.Errorf("worker id %d is leased already", r.lease.WorkerId())
+	}
+	maxWorkerId, err := r.rangeMax()
+	if err != nil {
+		return nil, err
+	}
+	for workerId := r.minWorkerId; workerId <= maxWorkerId; workerId++ {
+		claimed, err := r.claim(ctx, workerId)
+		if err != nil {
+			return nil, err
+		}
+		if claimed {
+			return r.lease, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: all worker ids [%d, %d] under %s are leased by alive nodes", ErrNoWorkerIdAvailable, r.minWorkerId, maxWorkerId, r.keyPrefix)
+}
+
+// ClaimWorkerId Claim the specified worker id, returns ErrWorkerIdConflict if it's leased by another node
+func (r *RedisWorkerIdAssigner) ClaimWorkerId(ctx context.Context, workerId int64) (WorkerLease, error) {
+	if r.lease != nil {
+		return nil, fmt.Errorf("worker id %d is leased already", r.lease.WorkerId())
+	}
+	claimed, err := r.claim(ctx, workerId)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, fmt.Errorf("%w: worker id %d is leased by another node in %s", ErrWorkerIdConflict, workerId, r.keyPrefix)
+	}
+	return r.lease, nil
+}
+
+// claim SET NX the key of workerId, start renewing once it's claimed
+func (r *RedisWorkerIdAssigner) claim(ctx context.Context, workerId int64) (bool, error) {
+	workerNode, err := buildWorkerNode(r.provider)
+	if err != nil {
+		return false, err
+	}
+	token := workerNode.HostName + ":" + workerNode.Port
+	now := time.Now()
+	claimed, err := r.client.SetNX(ctx, r.key(workerId), token, r.ttl).Result()
+	if err != nil || !claimed {
+		return false, err
+	}
+	r.token = token
+	r.lease = newRenewableWorkerLease(workerId, now.Add(r.ttl-r.deadlineMargin))
+	r.stop = make(chan struct{})
+	go keepWorkerLease(r.lease, r.renewInterval, r.ttl-r.deadlineMargin, r.stop, func(ctx context.Context, now time.Time) error {
+		return r.renew(ctx, workerId, token)
+	})
+	return true, nil
+}
+
+func (r *RedisWorkerIdAssigner) renew(ctx context.Context, workerId int64, token string) error {
+	renewed, err := redisRenewScript.Run(ctx, r.client, []string{r.key(workerId)}, token, r.ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if renewed == 0 {
+		return errWorkerLeaseTaken
+	}
+	return nil
+}
+
+// Release Stop renewing and delete the key if it's still owned by this node
+func (r *RedisWorkerIdAssigner) Release(ctx context.Context) error {
+	if r.lease == nil {
+		return nil
+	}
+	close(r.stop)
+	r.lease.lose(errors.New("released"))
+	err := redisReleaseScript.Run(ctx, r.client, []string{r.key(r.lease.WorkerId())}, r.token).Err()
+	r.lease = nil
+	return err
+}
+
+func (r *RedisWorkerIdAssigner) LoadLastTimestamp(ctx context.Context, workerId int64) (time.Time, error) {
+	lastTimestamp, err := r.client.Get(ctx, r.key(workerId)+":last").Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(lastTimestamp), nil
+}
+
+func (r *RedisWorkerIdAssigner) SaveLastTimestamp(ctx context.Context, workerId int64, lastTimestamp time.Time) error {
+	return redisSaveLastScript.Run(ctx, r.client, []string{r.key(workerId) + ":last"}, lastTimestamp.UnixMilli()).Err()
+}
+
+// key The worker ids of each datacenter are kept apart as <prefix>dc<datacenterId>:<workerId>
+func (r *RedisWorkerIdAssigner) key(workerId int64) string {
+	if r.bounds.Datacenter {
+		return r.keyPrefix + "dc" + strconv.FormatInt(r.bounds.DatacenterId, 10) + ":" + strconv.FormatInt(workerId, 10)
+	}
+	return r.keyPrefix + strconv.FormatInt(workerId, 10)
+}
@@ -0,0 +1,142 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisWorkerIdAssigner(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	ctx := context.Background()
+	newAssigner := func() *RedisWorkerIdAssigner {
+		assigner, err := NewRedisWorkerIdAssigner(client, WithRedisRange(0, 1),
+			WithRedisLeaseTTL(time.Second), WithRedisRenewInterval(100*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return assigner
+	}
+
+	first, second := newAssigner(), newAssigner()
+	defaultUidGenerator, err := NewDefaultUidGenerator(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedUidGenerator, err := NewCachedUidGenerator(defaultUidGenerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// stop padding and renewing before the client is closed
+	t.Cleanup(func() { _ = cachedUidGenerator.Shutdown(ctx) })
+	secondLease, err := second.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = second.Release(ctx) })
+	if defaultUidGenerator.workerId != 0 || secondLease.WorkerId() != 1 {
+		t.Fatalf("worker ids are %d and %d, want 0 and 1", defaultUidGenerator.workerId, secondLease.WorkerId())
+	}
+	if _, err := newAssigner().AssignWorkerId(ctx); !errors.Is(err, ErrNoWorkerIdAvailable) {
+		t.Fatalf("err is %v, want ErrNoWorkerIdAvailable", err)
+	}
+	// the local deadline is ahead of the key expiry by the margin of ttl/10
+	if deadline, _ := secondLease.Deadline(); time.Until(deadline) > 900*time.Millisecond {
+		t.Errorf("deadline is %v later, want within ttl - margin", time.Until(deadline))
+	}
+	if _, err := NewRedisWorkerIdAssigner(client, WithRedisLeaseTTL(time.Second), WithRedisRenewInterval(500*time.Millisecond),
+		WithRedisDeadlineMargin(500*time.Millisecond)); err == nil {
+		t.Error("renew interval plus margin of ttl should be refused")
+	}
+
+	// the lease is renewed in the background
+	time.Sleep(300 * time.Millisecond)
+	server.FastForward(800 * time.Millisecond)
+	if !server.Exists("uid:worker:0") {
+		t.Fatal("lease is not renewed")
+	}
+	if _, err := cachedUidGenerator.GetUID(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the released worker id can be claimed again
+	if err := second.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	third := newAssigner()
+	if _, err := third.AssignWorkerId(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = third.Release(ctx) })
+
+	// the generator is fenced once the worker id is taken by another node
+	server.Set("uid:worker:0", "another")
+	select {
+	case <-defaultUidGenerator.workerLease.Done():
+	case <-time.After(time.Second):
+		t.Fatal("lease is not lost after the worker id is taken")
+	}
+	if _, err := cachedUidGenerator.GetUID(); !errors.Is(err, ErrWorkerLeaseLost) {
+		t.Errorf("err is %v, want ErrWorkerLeaseLost", err)
+	}
+	if _, err := defaultUidGenerator.GetUID(); !errors.Is(err, ErrWorkerLeaseLost) {
+		t.Errorf("err is %v, want ErrWorkerLeaseLost", err)
+	}
+}
+
+func TestRedisWorkerIdRange(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	ctx := context.Background()
+	// [0, 0] is the range of worker id 0 only, not up to the bounds
+	first, err := NewRedisWorkerIdAssigner(client, WithRedisRange(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workerLease, err := first.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = first.Release(ctx) })
+	if workerLease.WorkerId() != 0 {
+		t.Errorf("worker id is %d, want 0", workerLease.WorkerId())
+	}
+	second, err := NewRedisWorkerIdAssigner(client, WithRedisRange(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.AssignWorkerId(ctx); !errors.Is(err, ErrNoWorkerIdAvailable) {
+		t.Errorf("err is %v, want ErrNoWorkerIdAvailable", err)
+	}
+
+	// the empty range is refused on construction
+	if _, err := NewRedisWorkerIdAssigner(client, WithRedisRange(3, 1)); err == nil {
+		t.Error("redis range [3, 1] should be refused")
+	}
+}
+
+func TestRedisHostIdentity(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	ctx := context.Background()
+	t.Setenv("HOSTNAME", "uid-host")
+	assigner, err := NewRedisWorkerIdAssigner(client, WithRedisHostIdentity(HostnameHostIdentityProvider{Port: "8080"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := assigner.AssignWorkerId(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = assigner.Release(ctx) })
+	if token, _ := server.Get("uid:worker:0"); token != "uid-host:8080" {
+		t.Errorf("token is %q, want uid-host:8080", token)
+	}
+}
@@ -0,0 +1,130 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrWorkerLeaseLost Returned by the generators once the worker lease can't be renewed, they are fenced from then on
+var ErrWorkerLeaseLost = errors.New("worker lease lost")
+
+// errWorkerLeaseTaken Returned by renew functions when another node owns the worker id now, there is no point to retry
+var errWorkerLeaseTaken = errors.New("worker id is taken by another node")
+
+/*
+renewableWorkerLease
+Represents a WorkerLease with a deadline, which is pushed forward by every successful renewal.
+*/
+type renewableWorkerLease struct {
+	workerId int64
+
+	mutex    sync.Mutex
+	deadline time.Time
+	done     chan struct{}
+	err      error
+}
+
+func newRenewableWorkerLease(workerId int64, deadline time.Time) *renewableWorkerLease {
+	return &renewableWorkerLease{
+		workerId: workerId,
+		deadline: deadline,
+		done:     make(chan struct{}),
+	}
+}
+
+func (r *renewableWorkerLease) WorkerId() int64 {
+	return r.workerId
+}
+
+func (r *renewableWorkerLease) Deadline() (time.Time, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.deadline, true
+}
+
+func (r *renewableWorkerLease) Done() <-chan struct{} {
+	return r.done
+}
+
+func (r *renewableWorkerLease) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
+
+// renew Push the deadline forward, a lost lease can't be renewed any more
+func (r *renewableWorkerLease) renew(deadline time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err == nil && deadline.After(r.deadline) {
+		r.deadline = deadline
+	}
+}
+
+// lose Mark the lease lost by cause, only the first cause is kept
+func (r *renewableWorkerLease) lose(cause error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = fmt.Errorf("%w: worker id %d: %v", ErrWorkerLeaseLost, r.workerId, cause)
+	close(r.done)
+}
+
+/*
+keepWorkerLease
+Call renew every interval until stop is closed. A successful renewal started at t pushes the deadline to t + ttl.
+The lease is lost when renew reports errWorkerLeaseTaken, or once the deadline passes without a successful renewal,
+even if renew is still blocked.
+*/
+func keepWorkerLease(lease *renewableWorkerLease, interval, ttl time.Duration, stop <-chan struct{}, renew func(ctx context.Context, now time.Time) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline, _ := lease.Deadline()
+	expire := time.NewTimer(time.Until(deadline))
+	defer expire.Stop()
+	renewed := make(chan error, 1)
+	var renewing bool
+	var now time.Time
+	var lastErr error
+	for {
+		select {
+		case <-stop:
+			return
+		case <-lease.Done():
+			return
+		case <-expire.C:
+			lease.lose(fmt.Errorf("deadline passed without renewal, last error: %v", lastErr))
+			return
+		case <-ticker.C:
+			if renewing {
+				continue
+			}
+			renewing, now = true, time.Now()
+			go func(now time.Time) {
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				defer cancel()
+				renewed <- renew(ctx, now)
+			}(now)
+			continue
+		case lastErr = <-renewed:
+			renewing = false
+		}
+		if errors.Is(lastErr, errWorkerLeaseTaken) {
+			lease.lose(lastErr)
+			return
+		}
+		if lastErr == nil {
+			lease.renew(now.Add(ttl))
+			deadline, _ := lease.Deadline()
+			if !expire.Stop() {
+				<-expire.C
+			}
+			expire.Reset(time.Until(deadline))
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package uidgenerator
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEpochRollover(t *testing.T) {
+	layout, err := defaultLayout(1, 27, 0, 22, 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epoch := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	rollover := time.Now().Add(-time.Hour).Truncate(time.Second)
+	before, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithLayout(layout), WithEpochTime(epoch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithBits(27, 22, 13), WithEpochTime(epoch), WithEpochRollover(rollover))
+	if err != nil {
+		t.Fatal(err)
+	}
+	beforeUid, err := before.GetUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	afterUid, err := after.GetUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the uid keep increasing across the rollover, though the delta is from the later epoch
+	if afterUid <= beforeUid {
+		t.Errorf("uid %d after the rollover is not greater than %d", afterUid, beforeUid)
+	}
+	var parsedBefore, parsedAfter map[string]string
+	if err := json.Unmarshal([]byte(before.ParseUID(beforeUid)), &parsedBefore); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(after.ParseUID(afterUid)), &parsedAfter); err != nil {
+		t.Fatal(err)
+	}
+	if parsedBefore["version"] != "0" || parsedAfter["version"] != "1" {
+		t.Errorf("versions are %s and %s, want 0 and 1", parsedBefore["version"], parsedAfter["version"])
+	}
+	if beforeTime, _ := time.ParseInLocation("2006-01-02 15:04:05", parsedBefore["timestamp"], time.Local); beforeTime.Before(rollover) {
+		t.Errorf("timestamp before the rollover is %s", parsedBefore["timestamp"])
+	}
+	if parsedAfter["timestamp"] < parsedBefore["timestamp"] {
+		t.Errorf("timestamp after the rollover %s is before %s", parsedAfter["timestamp"], parsedBefore["timestamp"])
+	}
+	if exhaustion := rollover.Add((1 << 27) * time.Second); !after.ExhaustionTime().Equal(exhaustion) {
+		t.Errorf("exhaustion time is %v, want %v", after.ExhaustionTime(), exhaustion)
+	}
+
+	for name, opts := range map[string][]OptionDefault{
+		"no version bit":   {WithEpochTime(epoch), WithEpochRollover(rollover)},
+		"before the epoch": {WithBits(27, 22, 13), WithEpochTime(epoch), WithEpochRollover(epoch.Add(-time.Hour))},
+		"after exhaustion": {WithBits(27, 22, 13), WithEpochTime(epoch), WithEpochRollover(epoch.Add((1 << 28) * time.Second))},
+		"no version left":  {WithBits(27, 22, 13), WithSegmentValue(SegmentVersion, 1), WithEpochTime(epoch), WithEpochRollover(rollover)},
+	} {
+		if _, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, opts...); err == nil {
+			t.Errorf("%s: the rollover should be refused", name)
+		}
+	}
+}
+
+func TestExhaustionWarning(t *testing.T) {
+	type warning struct {
+		threshold  time.Duration
+		exhaustion time.Time
+	}
+	warnings := make(chan warning, 4)
+	// 20 bits of milliseconds last about 17 minutes, they run out in 1.5s
+	epoch := time.Now().Add(-(1 << 20) * time.Millisecond).Add(1500 * time.Millisecond)
+	defaultUidGenerator, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{},
+		WithTimeUnit(TimeUnitMillisecond), WithBits(20, 30, 13), WithEpochTime(epoch),
+		WithExhaustionWarning(func(threshold time.Duration, exhaustion time.Time) {
+			warnings <- warning{threshold, exhaustion}
+		}, 2*time.Hour, time.Hour, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the passed thresholds are reported at once by the smallest
+	select {
+	case w := <-warnings:
+		if w.threshold != time.Hour || !w.exhaustion.Equal(defaultUidGenerator.ExhaustionTime()) {
+			t.Errorf("warning %v, want 1h before %v", w, defaultUidGenerator.ExhaustionTime())
+		}
+	default:
+		t.Fatal("the passed thresholds are not reported")
+	}
+	select {
+	case w := <-warnings:
+		if w.threshold != time.Second {
+			t.Errorf("threshold is %v, want 1s", w.threshold)
+		}
+		if left := time.Until(w.exhaustion); left > time.Second {
+			t.Errorf("warned %v before the exhaustion", left)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the threshold of 1s is not reported")
+	}
+}
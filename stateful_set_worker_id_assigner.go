@@ -0,0 +1,100 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultStatefulSetEnvKey = "HOSTNAME"
+
+/*
+StatefulSetWorkerIdAssigner
+Represents an implementation of WorkerIdAssigner for the pods of a Kubernetes StatefulSet.
+
+The pod name of a StatefulSet ends with a stable ordinal, such as web-3, so the worker id is the ordinal
+plus an optional per-cluster offset, a restarted pod keeps its worker id without any extra infrastructure.
+Make sure the StatefulSets sharing the worker id space have disjoint offsets.
+*/
+type StatefulSetWorkerIdAssigner struct {
+	envKey string
+	offset int64
+	bounds WorkerIdBounds
+}
+
+type OptionStatefulSet func(statefulSetWorkerIdAssigner *StatefulSetWorkerIdAssigner)
+
+// WithStatefulSetEnv The env var holding the pod name, default as HOSTNAME, such as POD_NAME set by the downward API
+func WithStatefulSetEnv(envKey string) OptionStatefulSet {
+	return func(statefulSetWorkerIdAssigner *StatefulSetWorkerIdAssigner) {
+		statefulSetWorkerIdAssigner.envKey = envKey
+	}
+}
+
+// WithStatefulSetOffset Added to the ordinal, such as 100 for the second cluster with at most 100 replicas
+func WithStatefulSetOffset(offset int64) OptionStatefulSet {
+	return func(statefulSetWorkerIdAssigner *StatefulSetWorkerIdAssigner) {
+		statefulSetWorkerIdAssigner.offset = offset
+	}
+}
+
+func NewStatefulSetWorkerIdAssigner(opts ...OptionStatefulSet) (*StatefulSetWorkerIdAssigner, error) {
+	assigner := StatefulSetWorkerIdAssigner{
+		envKey: defaultStatefulSetEnvKey,
+		bounds: WorkerIdBounds{MaxWorkerId: ^(-1 << defaultWorkerBits)},
+	}
+	for _, opt := range opts {
+		opt(&assigner)
+	}
+	if assigner.offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+	return &assigner, nil
+}
+
+func (s *StatefulSetWorkerIdAssigner) SetWorkerIdBounds(bounds WorkerIdBounds) {
+	s.bounds = bounds
+}
+
+func (s *StatefulSetWorkerIdAssigner) AssignWorkerId(ctx context.Context) (WorkerLease, error) {
+	podName := os.Getenv(s.envKey)
+	if podName == "" && s.envKey == defaultStatefulSetEnvKey {
+		var err error
+		if podName, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+	}
+	if podName == "" {
+		return nil, fmt.Errorf("missing pod name from env %s", s.envKey)
+	}
+	ordinal, err := parseStatefulSetOrdinal(podName)
+	if err != nil {
+		return nil, err
+	}
+	workerId := ordinal + s.offset
+	if workerId > s.bounds.MaxWorkerId {
+		return nil, fmt.Errorf("worker id %d of pod %s (ordinal %d + offset %d) exceeds the max %d", workerId, podName, ordinal, s.offset, s.bounds.MaxWorkerId)
+	}
+	return NewWorkerLease(workerId), nil
+}
+
+// Release The ordinal is stable, there is nothing to give back
+func (s *StatefulSetWorkerIdAssigner) Release(ctx context.Context) error {
+	return nil
+}
+
+// parseStatefulSetOrdinal Parse the ordinal from <statefulset name>-<ordinal>
+func parseStatefulSetOrdinal(podName string) (int64, error) {
+	index := strings.LastIndexByte(podName, '-')
+	if index < 0 {
+		return 0, fmt.Errorf("pod name %s doesn't end with -<ordinal>", podName)
+	}
+	ordinal, err := strconv.ParseInt(podName[index+1:], 10, 64)
+	if err != nil || ordinal < 0 {
+		return 0, fmt.Errorf("pod name %s doesn't end with -<ordinal>", podName)
+	}
+	return ordinal, nil
+}
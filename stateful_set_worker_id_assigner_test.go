@@ -0,0 +1,44 @@
+package uidgenerator
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStatefulSetWorkerIdAssigner(t *testing.T) {
+	tests := []struct {
+		podName  string
+		offset   int64
+		workerId int64
+		wantErr  bool
+	}{
+		{podName: "web-0", workerId: 0},
+		{podName: "web-3", workerId: 3},
+		{podName: "uid-generator-web-12", offset: 100, workerId: 112},
+		{podName: "web-1023", offset: 1, wantErr: true},
+		{podName: "web", wantErr: true},
+		{podName: "web-abc", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Setenv("POD_NAME", test.podName)
+		assigner, err := NewStatefulSetWorkerIdAssigner(WithStatefulSetEnv("POD_NAME"), WithStatefulSetOffset(test.offset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assigner.SetWorkerIdBounds(WorkerIdBounds{MaxWorkerId: 1023})
+		workerLease, err := assigner.AssignWorkerId(context.Background())
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: want error, got worker id %d", test.podName, workerLease.WorkerId())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.podName, err)
+			continue
+		}
+		if workerLease.WorkerId() != test.workerId {
+			t.Errorf("%s: worker id is %d, want %d", test.podName, workerLease.WorkerId(), test.workerId)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// WorkerIdEnvKey The env var pinning the worker id for NewEnvWorkerIdAssigner
+const WorkerIdEnvKey = "UID_WORKER_ID"
+
+/*
+StaticWorkerIdAssigner
+Represents an implementation of WorkerIdAssigner pinning the worker id from config or env var.
+
+The worker id is validated against the worker bits of the generator. Operators are responsible for the uniqueness,
+unless a registry is set, the worker id is claimed from the registry at startup and a conflict is refused.
+*/
+type StaticWorkerIdAssigner struct {
+	workerId int64
+	bounds   WorkerIdBounds
+	registry WorkerIdClaimer
+}
+
+type OptionStatic func(staticWorkerIdAssigner *StaticWorkerIdAssigner)
+
+// WithStaticRegistry Claim the worker id from registry at startup, such as a RedisWorkerIdAssigner shared by all nodes
+func WithStaticRegistry(registry WorkerIdClaimer) OptionStatic {
+	return func(staticWorkerIdAssigner *StaticWorkerIdAssigner) {
+		staticWorkerIdAssigner.registry = registry
+	}
+}
+
+func NewStaticWorkerIdAssigner(workerId int64, opts ...OptionStatic) (*StaticWorkerIdAssigner, error) {
+	if workerId < 0 {
+		return nil, fmt.Errorf("worker id %d must not be negative", workerId)
+	}
+	assigner := StaticWorkerIdAssigner{
+		workerId: workerId,
+		bounds:   WorkerIdBounds{MaxWorkerId: ^(-1 << defaultWorkerBits)},
+	}
+	for _, opt := range opts {
+		opt(&assigner)
+	}
+	return &assigner, nil
+}
+
+// NewEnvWorkerIdAssigner Constructor pinning the worker id from the env var UID_WORKER_ID
+func NewEnvWorkerIdAssigner(opts ...OptionStatic) (*StaticWorkerIdAssigner, error) {
+	value, ok := os.LookupEnv(WorkerIdEnvKey)
+	if !ok {
+		return nil, errors.New("missing worker id from env " + WorkerIdEnvKey)
+	}
+	workerId, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid worker id from env %s: %w", WorkerIdEnvKey, err)
+	}
+	return NewStaticWorkerIdAssigner(workerId, opts...)
+}
+
+func (s *StaticWorkerIdAssigner) SetWorkerIdBounds(bounds WorkerIdBounds) {
+	s.bounds = bounds
+	if bounded, ok := s.registry.(BoundedWorkerIdAssigner); ok {
+		bounded.SetWorkerIdBounds(bounds)
+	}
+}
+
+func (s *StaticWorkerIdAssigner) AssignWorkerId(ctx context.Context) (WorkerLease, error) {
+	if s.workerId > s.bounds.MaxWorkerId {
+		return nil, fmt.Errorf("worker id %d exceeds the max %d", s.workerId, s.bounds.MaxWorkerId)
+	}
+	if s.registry == nil {
+		return NewWorkerLease(s.workerId), nil
+	}
+	return s.registry.ClaimWorkerId(ctx, s.workerId)
+}
+
+// Release Give back the worker id to the registry if there is one
+func (s *StaticWorkerIdAssigner) Release(ctx context.Context) error {
+	if s.registry == nil {
+		return nil
+	}
+	return s.registry.Release(ctx)
+}
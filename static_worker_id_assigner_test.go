@@ -0,0 +1,59 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStaticWorkerIdAssigner(t *testing.T) {
+	t.Setenv(WorkerIdEnvKey, "5")
+	assigner, err := NewEnvWorkerIdAssigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultUidGenerator, err := NewDefaultUidGenerator(assigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultUidGenerator.workerId != 5 {
+		t.Errorf("worker id is %d, want 5", defaultUidGenerator.workerId)
+	}
+
+	// validated against the worker bits
+	assigner, err = NewStaticWorkerIdAssigner(1 << 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDefaultUidGenerator(assigner, WithBits(31, 10, 22)); err == nil {
+		t.Error("worker id exceeds the max should be refused")
+	}
+
+	// conflicts are detected by the registry
+	dir := t.TempDir()
+	ctx := context.Background()
+	newStatic := func() *StaticWorkerIdAssigner {
+		registry, err := NewFileLockWorkerIdAssigner(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assigner, err := NewStaticWorkerIdAssigner(7, WithStaticRegistry(registry))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return assigner
+	}
+	first := newStatic()
+	if _, err := first.AssignWorkerId(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newStatic().AssignWorkerId(ctx); !errors.Is(err, ErrWorkerIdConflict) {
+		t.Errorf("err is %v, want ErrWorkerIdConflict", err)
+	}
+	if err := first.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newStatic().AssignWorkerId(ctx); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,56 @@
+package uidgenerator
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+TimeUnit
+The unit of the timestamp segment, the sequence is reset every unit.
+TimeUnitSecond is the unit of the Java project, TimeUnitMillisecond is compatible with Twitter's Snowflake
+and TimeUnit10Millisecond with Sonyflake.
+*/
+type TimeUnit time.Duration
+
+const (
+	TimeUnitMillisecond   = TimeUnit(time.Millisecond)
+	TimeUnit10Millisecond = TimeUnit(10 * time.Millisecond)
+	TimeUnitSecond        = TimeUnit(time.Second)
+)
+
+func (t TimeUnit) String() string {
+	return time.Duration(t).String()
+}
+
+// Duration The duration of one unit
+func (t TimeUnit) Duration() time.Duration {
+	return time.Duration(t)
+}
+
+func (t TimeUnit) validate() error {
+	switch t {
+	case TimeUnitMillisecond, TimeUnit10Millisecond, TimeUnitSecond:
+		return nil
+	default:
+		return fmt.Errorf("time unit %v is not supported, must be 1ms, 10ms or 1s", t)
+	}
+}
+
+// timestamp The units elapsed since 1970-01-01 UTC until tm
+func (t TimeUnit) timestamp(tm time.Time) int64 {
+	return tm.UnixMilli() / time.Duration(t).Milliseconds()
+}
+
+// time The time of timestamp units since 1970-01-01 UTC
+func (t TimeUnit) time(timestamp int64) time.Time {
+	return time.UnixMilli(timestamp * time.Duration(t).Milliseconds())
+}
+
+// layout Format of the timestamp in ParseUID, the milliseconds are shown if the unit is less than a second
+func (t TimeUnit) layout() string {
+	if t < TimeUnitSecond {
+		return "2006-01-02 15:04:05.000"
+	}
+	return "2006-01-02 15:04:05"
+}
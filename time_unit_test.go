@@ -0,0 +1,65 @@
+package uidgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimeUnit(t *testing.T) {
+	for _, timeUnit := range []TimeUnit{TimeUnitMillisecond, TimeUnit10Millisecond} {
+		// the cached uid are padded in the constructor
+		before := time.Now().Truncate(timeUnit.Duration())
+		defaultUidGenerator, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{workerId: 7}, WithTimeUnit(timeUnit), WithBits(41, 10, 12))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cachedUidGenerator, err := NewCachedUidGenerator(defaultUidGenerator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, uidGenerator := range []UidGenerator{defaultUidGenerator, cachedUidGenerator} {
+			uid, err := uidGenerator.GetUID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the timestamp is in the unit since the epoch
+			thatTime := timeUnit.time(uid>>defaultUidGenerator.bitsAllocator.TimestampShift + defaultUidGenerator.epochTimestamp)
+			if thatTime.Before(before) || thatTime.After(before.Add(time.Second)) {
+				t.Errorf("%v: uid time %v, want about %v", timeUnit, thatTime, before)
+			}
+			var parsed map[string]string
+			if err := json.Unmarshal([]byte(uidGenerator.ParseUID(uid)), &parsed); err != nil {
+				t.Fatal(err)
+			}
+			if parsed["timestamp"] != thatTime.Format("2006-01-02 15:04:05.000") || parsed["workerId"] != "7" {
+				t.Errorf("%v: parsed %v, want timestamp %v and workerId 7", timeUnit, parsed, thatTime)
+			}
+		}
+		if err := cachedUidGenerator.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// more than MaxSequence+1 uid in a second
+	defaultUidGenerator, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithTimeUnit(TimeUnitMillisecond), WithBits(41, 17, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last int64
+	for i := 0; i < 1000; i++ {
+		uid, err := defaultUidGenerator.GetUID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uid <= last {
+			t.Fatalf("uid %d is not after %d", uid, last)
+		}
+		last = uid
+	}
+
+	if _, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{}, WithTimeUnit(TimeUnit(time.Minute))); err == nil {
+		t.Error("time unit of a minute should be refused")
+	}
+}
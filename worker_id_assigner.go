@@ -1,7 +1,151 @@
 package uidgenerator
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrNoWorkerIdAvailable Returned by the assigners choosing from a bounded range when every worker id is taken
+	ErrNoWorkerIdAvailable = errors.New("no worker id available")
+	// ErrWorkerIdConflict Returned by WorkerIdClaimer when the worker id is held by another node
+	ErrWorkerIdConflict = errors.New("worker id conflict")
+)
+
 // WorkerIdAssigner Represents a worker id assigner for DefaultUidGenerator
 type WorkerIdAssigner interface {
-	// Assign worker id for DefaultUidGenerator
-	assignWorkerId() (int64, error)
+	// AssignWorkerId Assign worker id for DefaultUidGenerator
+	AssignWorkerId(ctx context.Context) (WorkerLease, error)
+	// Release Give back the worker id assigned before, the UidGenerator must not use it anymore
+	Release(ctx context.Context) error
+}
+
+// WorkerIdBounds Describes the worker ids a DefaultUidGenerator is able to accept
+type WorkerIdBounds struct {
+	// MaxWorkerId Max worker id allowed by the worker bits, they are the machine bits if split with datacenter bits
+	MaxWorkerId int64
+	// Datacenter The worker id is scoped to DatacenterId, configured by WithDatacenter.
+	// The assigners sharing a registry across datacenters keep the worker ids of each datacenter apart
+	Datacenter   bool
+	DatacenterId int64
+}
+
+// BoundedWorkerIdAssigner Represents a WorkerIdAssigner choosing worker id from a bounded range,
+// DefaultUidGenerator hands over its bounds before assigning worker id
+type BoundedWorkerIdAssigner interface {
+	WorkerIdAssigner
+	// SetWorkerIdBounds Set the bounds the assigned worker id must respect
+	SetWorkerIdBounds(bounds WorkerIdBounds)
+}
+
+// workerIdRange The range [minWorkerId, maxWorkerId] a BoundedWorkerIdAssigner claims worker id from,
+// it's embedded by the assigners and keeps the bounds handed over by DefaultUidGenerator
+type workerIdRange struct {
+	minWorkerId int64
+	// maxWorkerId configured by the range option, up to the bounds unless rangeSet
+	maxWorkerId int64
+	rangeSet    bool
+	bounds      WorkerIdBounds
+}
+
+func newWorkerIdRange(minWorkerId int64) workerIdRange {
+	return workerIdRange{
+		minWorkerId: minWorkerId,
+		bounds:      WorkerIdBounds{MaxWorkerId: ^(-1 << defaultWorkerBits)},
+	}
+}
+
+// setRange Claim worker id in [minWorkerId, maxWorkerId] only, [0, 0] is the range of worker id 0
+func (w *workerIdRange) setRange(minWorkerId, maxWorkerId int64) {
+	w.minWorkerId = minWorkerId
+	w.maxWorkerId = maxWorkerId
+	w.rangeSet = true
+}
+
+// checkRange Refuse the empty range on construction
+func (w *workerIdRange) checkRange() error {
+	if w.rangeSet && w.minWorkerId > w.maxWorkerId {
+		return fmt.Errorf("worker id range [%d, %d] is empty", w.minWorkerId, w.maxWorkerId)
+	}
+	return nil
+}
+
+func (w *workerIdRange) SetWorkerIdBounds(bounds WorkerIdBounds) {
+	w.bounds = bounds
+}
+
+// rangeMax Returns the max worker id to claim, the max of the bounds unless the range is set
+func (w *workerIdRange) rangeMax() (int64, error) {
+	if !w.rangeSet {
+		return w.bounds.MaxWorkerId, nil
+	}
+	if w.maxWorkerId > w.bounds.MaxWorkerId {
+		return 0, fmt.Errorf("worker id range max %d exceeds the max worker id %d", w.maxWorkerId, w.bounds.MaxWorkerId)
+	}
+	return w.maxWorkerId, nil
+}
+
+// WorkerIdClaimer Represents a WorkerIdAssigner able to claim a specified worker id, it's used as a shared registry
+// to detect conflicts of the worker ids pinned by StaticWorkerIdAssigner
+type WorkerIdClaimer interface {
+	WorkerIdAssigner
+	// ClaimWorkerId Claim workerId instead of choosing one, returns ErrWorkerIdConflict if it's held by another node
+	ClaimWorkerId(ctx context.Context, workerId int64) (WorkerLease, error)
+}
+
+/*
+LastTimestampKeeper
+Represents a WorkerIdAssigner able to keep the last timestamp used by each worker id.
+A reused worker id is safe only if the new owner never generates uid at or before the last second
+the previous owner used, the generators save their last timestamp periodically and on shutdown,
+and refuse to generate uid until the clock passes the last timestamp loaded on startup.
+*/
+type LastTimestampKeeper interface {
+	// LoadLastTimestamp Returns the last timestamp used by the worker id, zero time if it's never used
+	LoadLastTimestamp(ctx context.Context, workerId int64) (time.Time, error)
+	// SaveLastTimestamp Keep the last timestamp used by the worker id, it never goes back
+	SaveLastTimestamp(ctx context.Context, workerId int64, lastTimestamp time.Time) error
+}
+
+/*
+WorkerLease
+Represents a worker id held by the UidGenerator.
+The methods follow the shape of context.Context: a lease without deadline never expires,
+and Done is closed once the lease is lost, after that Err explains why.
+*/
+type WorkerLease interface {
+	// WorkerId The assigned worker id
+	WorkerId() int64
+	// Deadline Returns the time when the lease expires, ok is false when the lease never expires
+	Deadline() (deadline time.Time, ok bool)
+	// Done Returns a channel closed when the lease is lost, nil if the lease can never be lost
+	Done() <-chan struct{}
+	// Err Returns nil while Done is not closed, otherwise the reason the lease is lost
+	Err() error
+}
+
+// NewWorkerLease Returns a WorkerLease of workerId which never expires
+func NewWorkerLease(workerId int64) WorkerLease {
+	return permanentWorkerLease(workerId)
+}
+
+// permanentWorkerLease Represents a worker id owned forever, such as assigned by DisposableWorkerIdAssigner
+type permanentWorkerLease int64
+
+func (p permanentWorkerLease) WorkerId() int64 {
+	return int64(p)
+}
+
+func (p permanentWorkerLease) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (p permanentWorkerLease) Done() <-chan struct{} {
+	return nil
+}
+
+func (p permanentWorkerLease) Err() error {
+	return nil
 }
@@ -0,0 +1,99 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fixedWorkerIdAssigner struct {
+	workerId int64
+	released bool
+}
+
+func (f *fixedWorkerIdAssigner) AssignWorkerId(ctx context.Context) (WorkerLease, error) {
+	return NewWorkerLease(f.workerId), nil
+}
+
+func (f *fixedWorkerIdAssigner) Release(ctx context.Context) error {
+	f.released = true
+	return nil
+}
+
+func TestCustomWorkerIdAssigner(t *testing.T) {
+	workerIdAssigner := &fixedWorkerIdAssigner{workerId: 42}
+	defaultUidGenerator, err := NewDefaultUidGenerator(workerIdAssigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, err := defaultUidGenerator.GetUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workerId := (uid >> defaultUidGenerator.bitsAllocator.WorkerIdShift) & defaultUidGenerator.bitsAllocator.MaxWorkerId; workerId != 42 {
+		t.Errorf("worker id of uid is %d, want 42", workerId)
+	}
+	if err := defaultUidGenerator.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !workerIdAssigner.released {
+		t.Error("worker id is not released after shutdown")
+	}
+	workerIdAssigner.released = false
+	if err := defaultUidGenerator.Shutdown(context.Background()); err != nil || workerIdAssigner.released {
+		t.Errorf("second shutdown err %v, released %v, want a no-op", err, workerIdAssigner.released)
+	}
+
+	if _, err := NewDefaultUidGenerator(&fixedWorkerIdAssigner{workerId: 1 << 22}); err == nil {
+		t.Error("worker id exceeds the max should be refused")
+	}
+}
+
+// hangingWorkerIdAssigner Assigns a lease whose renewal hangs until stop
+type hangingWorkerIdAssigner struct {
+	lease *renewableWorkerLease
+	stop  chan struct{}
+}
+
+func (h *hangingWorkerIdAssigner) AssignWorkerId(ctx context.Context) (WorkerLease, error) {
+	h.lease = newRenewableWorkerLease(1, time.Now().Add(200*time.Millisecond))
+	h.stop = make(chan struct{})
+	go keepWorkerLease(h.lease, 50*time.Millisecond, 200*time.Millisecond, h.stop, func(ctx context.Context, now time.Time) error {
+		<-h.stop
+		return nil
+	})
+	return h.lease, nil
+}
+
+func (h *hangingWorkerIdAssigner) Release(ctx context.Context) error {
+	close(h.stop)
+	return nil
+}
+
+func TestWorkerLeaseDeadline(t *testing.T) {
+	workerIdAssigner := &hangingWorkerIdAssigner{}
+	lost := make(chan error, 1)
+	defaultUidGenerator, err := NewDefaultUidGenerator(workerIdAssigner, WithWorkerLeaseLostHandler(func(err error) {
+		lost <- err
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer defaultUidGenerator.Shutdown(context.Background())
+	if _, err := defaultUidGenerator.GetUID(); err != nil {
+		t.Fatal(err)
+	}
+	// the renewal hangs, the lease is lost at the deadline anyway
+	select {
+	case err := <-lost:
+		if !errors.Is(err, ErrWorkerLeaseLost) {
+			t.Errorf("err is %v, want ErrWorkerLeaseLost", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the lease is not lost at the deadline")
+	}
+	if _, err := defaultUidGenerator.GetUID(); !errors.Is(err, ErrWorkerLeaseLost) {
+		t.Errorf("err is %v, want ErrWorkerLeaseLost", err)
+	}
+}
@@ -2,27 +2,44 @@ package uidgenerator
 
 import "time"
 
+// WorkerNodeType How the node is identified in WORKER_NODE
+type WorkerNodeType int
+
 const (
-	container = iota + 1
-	actual
+	// WorkerNodeTypeContainer The node is identified by the host name and port of the container
+	WorkerNodeTypeContainer WorkerNodeType = iota + 1
+	// WorkerNodeTypeActual The node is identified by the ip and launch time of the actual machine
+	WorkerNodeTypeActual
 )
 
+func (w WorkerNodeType) String() string {
+	switch w {
+	case WorkerNodeTypeContainer:
+		return "CONTAINER"
+	case WorkerNodeTypeActual:
+		return "ACTUAL"
+	}
+	return "UNKNOWN"
+}
+
 /*
-workerNode for M_WORKER_NODE
+WorkerNode for M_WORKER_NODE
 */
-type workerNode struct {
+type WorkerNode struct {
 	// unique id (table unique)
-	Id uint64
+	Id int64
 	// Type of CONTAINER: HostName, ACTUAL : IP.
 	HostName string
 	// Type of CONTAINER: Port, ACTUAL : Timestamp + Random(0-10000)
 	Port string
 	// Type of CONTAINER or Actual
-	Type int
+	Type WorkerNodeType
 	// Worker launch date, default now
-	launchDate time.Time
+	LaunchDate time.Time
 	// Created time
-	created time.Time
-	// Last modified
-	modified time.Time
+	Created time.Time
+	// Last modified, it's the heartbeat of LeaseWorkerIdAssigner
+	Modified time.Time
+	// Last timestamp used by the worker id, zero if it's never saved
+	LastTimestamp time.Time
 }
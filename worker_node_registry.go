@@ -0,0 +1,145 @@
+package uidgenerator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrWorkerNodeNotFound Returned by WorkerNodeRegistry when there is no worker node of the id
+var ErrWorkerNodeNotFound = errors.New("worker node not found")
+
+/*
+WorkerNodeRegistry
+Represents the worker nodes in WORKER_NODE, to answer which node generated a uid and to clean up the table.
+The table must be migrated by EnsureSchema, MySQL needs parseTime=true in the dsn to scan the times.
+*/
+type WorkerNodeRegistry struct {
+	db      *sql.DB
+	dialect Dialect
+	table   WorkerNodeTable
+
+	listSql    string
+	getSql     string
+	findSql    string
+	staleSql   string
+	maxIdSql   string
+	reclaimSql string
+}
+
+type OptionRegistry func(workerNodeRegistry *WorkerNodeRegistry)
+
+// WithRegistryTable Names of the worker node table and its columns, default as DefaultWorkerNodeTable
+func WithRegistryTable(table WorkerNodeTable) OptionRegistry {
+	return func(workerNodeRegistry *WorkerNodeRegistry) {
+		workerNodeRegistry.table = table
+	}
+}
+
+// NewWorkerNodeRegistry Constructor with an opened db and the dialect of it
+func NewWorkerNodeRegistry(db *sql.DB, dialect Dialect, opts ...OptionRegistry) *WorkerNodeRegistry {
+	registry := WorkerNodeRegistry{
+		db:      db,
+		dialect: dialect,
+		table:   DefaultWorkerNodeTable(),
+	}
+	for _, opt := range opts {
+		opt(&registry)
+	}
+	selectSql := registry.table.render(`
+SELECT
+		ID,
+		HOST_NAME,
+		PORT,
+		TYPE,
+		LAUNCH_DATE,
+		MODIFIED,
+		CREATED,
+		LAST_TIMESTAMP
+FROM
+		WORKER_NODE
+`)
+	registry.listSql = selectSql + registry.table.render(`ORDER BY ID`)
+	registry.getSql = dialect.Rebind(selectSql + registry.table.render(`WHERE ID = ?`))
+	registry.findSql = dialect.Rebind(selectSql + registry.table.render(`WHERE HOST_NAME = ? AND PORT = ? ORDER BY ID`))
+	registry.staleSql = dialect.Rebind(selectSql + registry.table.render(`WHERE MODIFIED < ? ORDER BY ID`))
+	registry.maxIdSql = registry.table.render(`SELECT MAX(ID) FROM WORKER_NODE`)
+	registry.reclaimSql = dialect.Rebind(registry.table.render(`
+DELETE FROM WORKER_NODE WHERE MODIFIED < ? AND LAST_TIMESTAMP < ? AND ID < ?
+`))
+	return &registry
+}
+
+// List All the worker nodes ordered by id
+func (w *WorkerNodeRegistry) List(ctx context.Context) ([]WorkerNode, error) {
+	return w.query(ctx, w.listSql)
+}
+
+// Get The worker node of id, such as the worker id parsed from a uid, ErrWorkerNodeNotFound if there is none
+func (w *WorkerNodeRegistry) Get(ctx context.Context, id int64) (*WorkerNode, error) {
+	workerNodes, err := w.query(ctx, w.getSql, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(workerNodes) == 0 {
+		return nil, ErrWorkerNodeNotFound
+	}
+	return &workerNodes[0], nil
+}
+
+// FindByHostPort The worker nodes launched on host and port, a container restarted with the same identity has several
+func (w *WorkerNodeRegistry) FindByHostPort(ctx context.Context, hostName, port string) ([]WorkerNode, error) {
+	return w.query(ctx, w.findSql, hostName, port)
+}
+
+// ListStale The worker nodes not modified within olderThan, they are the candidates of ReclaimStale
+func (w *WorkerNodeRegistry) ListStale(ctx context.Context, olderThan time.Duration) ([]WorkerNode, error) {
+	return w.query(ctx, w.staleSql, time.Now().Add(-olderThan).UTC())
+}
+
+/*
+ReclaimStale
+Delete the worker nodes not modified within olderThan, returns the number of deleted nodes.
+olderThan must be far longer than the lease ttl of LeaseWorkerIdAssigner, the lease of a live node is never that old.
+A node whose last timestamp is within olderThan is kept, its worker id can't be reused safely yet.
+The node of the max id is always kept, MySQL before 8.0 resets AUTO_INCREMENT to the max id on restart,
+the disposable worker ids would be assigned again without it.
+*/
+func (w *WorkerNodeRegistry) ReclaimStale(ctx context.Context, olderThan time.Duration) (int64, error) {
+	if olderThan <= 0 {
+		return 0, errors.New("age of stale worker nodes must be positive")
+	}
+	var maxId sql.NullInt64
+	if err := w.db.QueryRowContext(ctx, w.maxIdSql).Scan(&maxId); err != nil || !maxId.Valid {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	result, err := w.db.ExecContext(ctx, w.reclaimSql, cutoff.UTC(), cutoff.UnixMilli(), maxId.Int64)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (w *WorkerNodeRegistry) query(ctx context.Context, query string, args ...any) ([]WorkerNode, error) {
+	rows, err := w.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var workerNodes []WorkerNode
+	for rows.Next() {
+		var workerNode WorkerNode
+		var lastTimestamp int64
+		if err := rows.Scan(&workerNode.Id, &workerNode.HostName, &workerNode.Port, &workerNode.Type,
+			&workerNode.LaunchDate, &workerNode.Modified, &workerNode.Created, &lastTimestamp); err != nil {
+			return nil, err
+		}
+		if lastTimestamp > 0 {
+			workerNode.LastTimestamp = time.UnixMilli(lastTimestamp)
+		}
+		workerNodes = append(workerNodes, workerNode)
+	}
+	return workerNodes, rows.Err()
+}
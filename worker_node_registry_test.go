@@ -0,0 +1,80 @@
+package uidgenerator
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWorkerNodeRegistry(t *testing.T) {
+	assigner, err := NewSQLiteWorkerIdAssigner(filepath.Join(t.TempDir(), "uid_generator.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer assigner.db.Close()
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := assigner.AssignWorkerId(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	registry := assigner.Registry()
+
+	workerNodes, err := registry.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workerNodes) != 3 {
+		t.Fatalf("%d worker nodes, want 3", len(workerNodes))
+	}
+	workerNode, err := registry.Get(ctx, workerNodes[1].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workerNode.HostName != workerNodes[1].HostName || workerNode.Port != workerNodes[1].Port || workerNode.LaunchDate.IsZero() {
+		t.Errorf("worker node is %+v, want %+v", workerNode, workerNodes[1])
+	}
+	if _, err := registry.Get(ctx, 100); !errors.Is(err, ErrWorkerNodeNotFound) {
+		t.Errorf("err is %v, want ErrWorkerNodeNotFound", err)
+	}
+	found, err := registry.FindByHostPort(ctx, workerNode.HostName, workerNode.Port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contained := false
+	for _, node := range found {
+		contained = contained || node.Id == workerNode.Id
+		if node.HostName != workerNode.HostName || node.Port != workerNode.Port {
+			t.Errorf("found %+v on another host and port", node)
+		}
+	}
+	if !contained {
+		t.Errorf("found %+v, want worker node %d", found, workerNode.Id)
+	}
+
+	// all the nodes are stale in the future, but the node of the max id is kept
+	time.Sleep(10 * time.Millisecond)
+	stale, err := registry.ListStale(ctx, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 3 {
+		t.Errorf("%d stale worker nodes, want 3", len(stale))
+	}
+	reclaimed, err := registry.ReclaimStale(ctx, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reclaimed != 2 {
+		t.Errorf("%d worker nodes reclaimed, want 2", reclaimed)
+	}
+	workerLease, err := assigner.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workerLease.WorkerId() != 4 {
+		t.Errorf("worker id is %d after reclaiming, want 4", workerLease.WorkerId())
+	}
+}
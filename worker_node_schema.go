@@ -0,0 +1,166 @@
+package uidgenerator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+/*
+WorkerNodeTable
+Names of the WORKER_NODE table and its columns. The statements of the assigners and the migrations
+are written with the default names, which are replaced by the configured ones.
+The names are put into the statements as is, they must be trusted configuration.
+*/
+type WorkerNodeTable struct {
+	// Name Name of the worker node table, such as uid.WORKER_NODE for another schema
+	Name string
+	// VersionName Name of the table recording the applied migrations
+	VersionName   string
+	Id            string
+	HostName      string
+	Port          string
+	Type          string
+	LaunchDate    string
+	Modified      string
+	Created       string
+	LastTimestamp string
+}
+
+// DefaultWorkerNodeTable Names of the Java project
+func DefaultWorkerNodeTable() WorkerNodeTable {
+	return WorkerNodeTable{
+		Name:          "WORKER_NODE",
+		VersionName:   "WORKER_NODE_VERSION",
+		Id:            "ID",
+		HostName:      "HOST_NAME",
+		Port:          "PORT",
+		Type:          "TYPE",
+		LaunchDate:    "LAUNCH_DATE",
+		Modified:      "MODIFIED",
+		Created:       "CREATED",
+		LastTimestamp: "LAST_TIMESTAMP",
+	}
+}
+
+// WithPrefix Returns the names with the tables prefixed, such as UID_WORKER_NODE in a shared schema
+func (w WorkerNodeTable) WithPrefix(prefix string) WorkerNodeTable {
+	w.Name = prefix + w.Name
+	w.VersionName = prefix + w.VersionName
+	return w
+}
+
+var workerNodeIdentifier = regexp.MustCompile(`\b[A-Z_]+\b`)
+
+// render Replace the default names in query by the configured ones
+func (w WorkerNodeTable) render(query string) string {
+	names := map[string]string{
+		"WORKER_NODE":         w.Name,
+		"WORKER_NODE_VERSION": w.VersionName,
+		"ID":                  w.Id,
+		"HOST_NAME":           w.HostName,
+		"PORT":                w.Port,
+		"TYPE":                w.Type,
+		"LAUNCH_DATE":         w.LaunchDate,
+		"MODIFIED":            w.Modified,
+		"CREATED":             w.Created,
+		"LAST_TIMESTAMP":      w.LastTimestamp,
+	}
+	return workerNodeIdentifier.ReplaceAllStringFunc(query, func(identifier string) string {
+		if name, ok := names[identifier]; ok {
+			return name
+		}
+		return identifier
+	})
+}
+
+/*
+Migration
+A versioned step of the WORKER_NODE schema, the statements are written with the default names.
+Tables created by hand or by the Java project have no version recorded, Applied detects such steps.
+*/
+type Migration struct {
+	Version     int
+	Description string
+	Statements  []string
+	// Applied A query succeeding if the step is applied already, empty if the statements are idempotent
+	Applied string
+}
+
+// SchemaEnsurer Represents a WorkerIdAssigner able to create and migrate the tables it needs
+type SchemaEnsurer interface {
+	// EnsureSchema Apply the migrations not applied yet, in the order of version
+	EnsureSchema(ctx context.Context) error
+}
+
+const workerNodeVersionSchema = `
+CREATE TABLE IF NOT EXISTS WORKER_NODE_VERSION
+(
+    VERSION     INT          NOT NULL PRIMARY KEY,
+    DESCRIPTION VARCHAR(255) NOT NULL,
+    CREATED     TIMESTAMP    NOT NULL
+)
+`
+
+/*
+ensureWorkerNodeSchema
+Apply the migrations of dialect not recorded in WORKER_NODE_VERSION.
+Nodes may boot at the same time, a step failed because another node applied it is not an error.
+*/
+func ensureWorkerNodeSchema(ctx context.Context, db *sql.DB, dialect Dialect, table WorkerNodeTable) error {
+	if _, err := db.ExecContext(ctx, table.render(workerNodeVersionSchema)); err != nil {
+		return err
+	}
+	selectVersionSql := dialect.Rebind(table.render(`SELECT COUNT(*) FROM WORKER_NODE_VERSION WHERE VERSION = ?`))
+	insertVersionSql := dialect.Rebind(table.render(`INSERT INTO WORKER_NODE_VERSION (VERSION,DESCRIPTION,CREATED) VALUES (?,?,?)`))
+	isRecorded := func(version int) (bool, error) {
+		var count int
+		err := db.QueryRowContext(ctx, selectVersionSql, version).Scan(&count)
+		return count > 0, err
+	}
+	// isApplied Only a missing table or column means the step is not applied, other errors are returned
+	isApplied := func(migration Migration) (bool, error) {
+		if migration.Applied == "" {
+			return false, nil
+		}
+		rows, err := db.QueryContext(ctx, table.render(migration.Applied))
+		if err != nil {
+			if dialect.IsMissingObject(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, rows.Close()
+	}
+	for _, migration := range dialect.Migrations() {
+		recorded, err := isRecorded(migration.Version)
+		if err != nil {
+			return err
+		}
+		if recorded {
+			continue
+		}
+		for _, statement := range migration.Statements {
+			applied, err := isApplied(migration)
+			if err != nil {
+				return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Description, err)
+			}
+			if applied {
+				break
+			}
+			if _, err := db.ExecContext(ctx, table.render(statement)); err != nil {
+				if applied, _ := isApplied(migration); !applied {
+					return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Description, err)
+				}
+			}
+		}
+		if _, err := db.ExecContext(ctx, insertVersionSql, migration.Version, migration.Description, time.Now().UTC()); err != nil {
+			if recorded, _ := isRecorded(migration.Version); !recorded {
+				return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Description, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package uidgenerator
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnsureSchema(t *testing.T) {
+	db, err := sql.Open(SQLiteDialect{}.DriverName(), "file:"+filepath.Join(t.TempDir(), "uid_generator.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	// a table created by hand before the migrations, the last timestamp column is added by hand too
+	if _, err := db.Exec(`
+CREATE TABLE WORKER_NODE
+(
+    ID INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, HOST_NAME VARCHAR(64) NOT NULL, PORT VARCHAR(64) NOT NULL,
+    TYPE INT NOT NULL, LAUNCH_DATE DATE NOT NULL, MODIFIED TIMESTAMP NOT NULL, CREATED TIMESTAMP NOT NULL,
+    LAST_TIMESTAMP BIGINT NOT NULL DEFAULT 0
+)`); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := NewDisposableWorkerIdAssignerWithDB(db, SQLiteDialect{}).EnsureSchema(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var versions int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM WORKER_NODE_VERSION`).Scan(&versions); err != nil {
+		t.Fatal(err)
+	}
+	if versions != len(SQLiteDialect{}.Migrations()) {
+		t.Errorf("%d versions recorded, want %d", versions, len(SQLiteDialect{}.Migrations()))
+	}
+
+	// a prefixed table with renamed columns in the same database
+	table := DefaultWorkerNodeTable().WithPrefix("UID_")
+	table.Modified = "GMT_MODIFIED"
+	assigner, err := NewLeaseWorkerIdAssigner(db, SQLiteDialect{}, WithLeaseTable(table),
+		WithLeaseTTL(3*time.Second), WithLeaseHeartbeatInterval(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := assigner.EnsureSchema(ctx); err != nil {
+		t.Fatal(err)
+	}
+	workerLease, err := assigner.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := assigner.SaveLastTimestamp(ctx, workerLease.WorkerId(), time.UnixMilli(42)); err != nil {
+		t.Fatal(err)
+	}
+	var lastTimestamp int64
+	if err := db.QueryRow(`SELECT LAST_TIMESTAMP FROM UID_WORKER_NODE WHERE GMT_MODIFIED IS NOT NULL`).Scan(&lastTimestamp); err != nil {
+		t.Fatal(err)
+	}
+	if lastTimestamp != 42 {
+		t.Errorf("last timestamp is %d, want 42", lastTimestamp)
+	}
+	if err := assigner.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// brokenAppliedDialect A dialect whose Applied query fails for another reason than a missing column
+type brokenAppliedDialect struct {
+	SQLiteDialect
+}
+
+func (b brokenAppliedDialect) Migrations() []Migration {
+	migrations := b.SQLiteDialect.Migrations()
+	migrations[1].Applied = `SELECT LAST_TIMESTAMP FROM WORKER_NODE WHERE`
+	return migrations
+}
+
+func TestEnsureSchemaAppliedError(t *testing.T) {
+	db, err := sql.Open(SQLiteDialect{}.DriverName(), "file:"+filepath.Join(t.TempDir(), "uid_generator.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if _, err := db.Query(lastTimestampApplied); !(SQLiteDialect{}).IsMissingObject(err) {
+		t.Fatalf("err is %v, want the missing table", err)
+	}
+	// the step must not be applied if it's unknown whether it's applied already
+	if err := ensureWorkerNodeSchema(ctx, db, brokenAppliedDialect{}, DefaultWorkerNodeTable()); err == nil {
+		t.Fatal("the error of the applied query is swallowed")
+	}
+	var versions int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM WORKER_NODE_VERSION`).Scan(&versions); err != nil {
+		t.Fatal(err)
+	}
+	if versions != 1 {
+		t.Errorf("%d versions recorded, want 1", versions)
+	}
+}
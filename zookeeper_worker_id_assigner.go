@@ -0,0 +1,205 @@
+package uidgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+)
+
+/*
+ZookeeperWorkerIdAssigner
+Represents an implementation of WorkerIdAssigner compatible with Meituan Leaf's snowflake mode.
+
+It shares the znode layout of Leaf, so the machines migrated from Leaf keep their worker ids:
+each ip:port owns a persistent sequential znode /snowflake/<leafName>/forever/<ip>:<port>-<sequence>,
+the sequence is the worker id. If the znode of ip:port exists, its worker id is reused, otherwise a new one is created.
+
+The znode data is the Leaf endpoint {"ip":"","port":"","timestamp":0}, it's a LastTimestampKeeper
+keeping the last timestamp in the data for the clock rollback check on startup, like Leaf does.
+
+The requests of zk.Conn can't be canceled, ctx is checked before each of them, a request in flight is bounded by the session timeout.
+*/
+type ZookeeperWorkerIdAssigner struct {
+	conn     zookeeperConn
+	leafName string
+	ip       string
+	port     string
+
+	nodePath string
+	workerId int64
+}
+
+// zookeeperConn The requests of zk.Conn used by ZookeeperWorkerIdAssigner
+type zookeeperConn interface {
+	Children(path string) ([]string, *zk.Stat, error)
+	Get(path string) ([]byte, *zk.Stat, error)
+	Set(path string, data []byte, version int32) (*zk.Stat, error)
+	Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error)
+}
+
+// leafEndpoint The znode data of Leaf
+type leafEndpoint struct {
+	Ip        string `json:"ip"`
+	Port      string `json:"port"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type OptionZookeeper func(zookeeperWorkerIdAssigner *ZookeeperWorkerIdAssigner)
+
+// WithZookeeperIp The ip of ip:port, default as the local address
+func WithZookeeperIp(ip string) OptionZookeeper {
+	return func(zookeeperWorkerIdAssigner *ZookeeperWorkerIdAssigner) {
+		zookeeperWorkerIdAssigner.ip = ip
+	}
+}
+
+// NewZookeeperWorkerIdAssigner Constructor with a zookeeper connection, the leaf.name of Leaf and the listen port of the service
+func NewZookeeperWorkerIdAssigner(conn *zk.Conn, leafName, port string, opts ...OptionZookeeper) (*ZookeeperWorkerIdAssigner, error) {
+	return newZookeeperWorkerIdAssigner(conn, leafName, port, opts...)
+}
+
+func newZookeeperWorkerIdAssigner(conn zookeeperConn, leafName, port string, opts ...OptionZookeeper) (*ZookeeperWorkerIdAssigner, error) {
+	assigner := ZookeeperWorkerIdAssigner{
+		conn:     conn,
+		leafName: leafName,
+		port:     port,
+	}
+	for _, opt := range opts {
+		opt(&assigner)
+	}
+	if assigner.ip == "" {
+		localAddress, err := LocalAddress()
+		if err != nil {
+			return nil, err
+		}
+		assigner.ip = localAddress
+	}
+	if leafName == "" || assigner.ip == "" || port == "" {
+		return nil, errors.New("leaf name, ip and port are required")
+	}
+	return &assigner, nil
+}
+
+func (z *ZookeeperWorkerIdAssigner) AssignWorkerId(ctx context.Context) (WorkerLease, error) {
+	foreverPath := "/snowflake/" + z.leafName + "/forever"
+	listenAddress := z.ip + ":" + z.port
+	if err := z.createParents(ctx, foreverPath); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	children, _, err := z.conn.Children(foreverPath)
+	if err != nil {
+		return nil, err
+	}
+	// reuse the znode of ip:port
+	for _, child := range children {
+		address, sequence, ok := strings.Cut(child, "-")
+		if !ok || address != listenAddress {
+			continue
+		}
+		workerId, err := strconv.ParseInt(sequence, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid znode %s/%s: %w", foreverPath, child, err)
+		}
+		z.nodePath, z.workerId = foreverPath+"/"+child, workerId
+		return NewWorkerLease(workerId), nil
+	}
+	// a new node, no need to check the timestamp
+	data, err := json.Marshal(leafEndpoint{Ip: z.ip, Port: z.port, Timestamp: time.Now().UnixMilli()})
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	nodePath, err := z.conn.Create(foreverPath+"/"+listenAddress+"-", data, zk.FlagSequence, zk.WorldACL(zk.PermAll))
+	if err != nil {
+		return nil, err
+	}
+	workerId, err := strconv.ParseInt(nodePath[strings.LastIndex(nodePath, "-")+1:], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid znode %s: %w", nodePath, err)
+	}
+	z.nodePath, z.workerId = nodePath, workerId
+	return NewWorkerLease(workerId), nil
+}
+
+// Release The znode is persistent, ip:port keeps the worker id for next launch
+func (z *ZookeeperWorkerIdAssigner) Release(ctx context.Context) error {
+	return nil
+}
+
+func (z *ZookeeperWorkerIdAssigner) LoadLastTimestamp(ctx context.Context, workerId int64) (time.Time, error) {
+	endpoint, _, err := z.loadEndpoint(ctx, workerId)
+	if err != nil || endpoint.Timestamp == 0 {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(endpoint.Timestamp), nil
+}
+
+// SaveLastTimestamp Set the data with the version read, so the timestamp never goes back
+func (z *ZookeeperWorkerIdAssigner) SaveLastTimestamp(ctx context.Context, workerId int64, lastTimestamp time.Time) error {
+	for {
+		endpoint, stat, err := z.loadEndpoint(ctx, workerId)
+		if err != nil {
+			return err
+		}
+		if endpoint.Timestamp >= lastTimestamp.UnixMilli() {
+			return nil
+		}
+		data, err := json.Marshal(leafEndpoint{Ip: z.ip, Port: z.port, Timestamp: lastTimestamp.UnixMilli()})
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := z.conn.Set(z.nodePath, data, stat.Version); !errors.Is(err, zk.ErrBadVersion) {
+			return err
+		}
+	}
+}
+
+func (z *ZookeeperWorkerIdAssigner) loadEndpoint(ctx context.Context, workerId int64) (*leafEndpoint, *zk.Stat, error) {
+	if z.nodePath == "" || workerId != z.workerId {
+		return nil, nil, fmt.Errorf("worker id %d is not assigned by this assigner", workerId)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	data, stat, err := z.conn.Get(z.nodePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	endpoint := leafEndpoint{}
+	if len(data) != 0 {
+		if err := json.Unmarshal(data, &endpoint); err != nil {
+			return nil, nil, fmt.Errorf("invalid data of znode %s: %w", z.nodePath, err)
+		}
+	}
+	return &endpoint, stat, nil
+}
+
+// createParents Create the persistent znodes of path if not exist
+func (z *ZookeeperWorkerIdAssigner) createParents(ctx context.Context, path string) error {
+	current := ""
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		current += "/" + part
+		_, err := z.conn.Create(current, nil, 0, zk.WorldACL(zk.PermAll))
+		if err != nil && !errors.Is(err, zk.ErrNodeExists) {
+			return err
+		}
+	}
+	return nil
+}
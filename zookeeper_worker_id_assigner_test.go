@@ -0,0 +1,171 @@
+package uidgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+)
+
+// fakeZookeeper An in-memory zookeeperConn keeping the znode tree, the sequence and the data version
+type fakeZookeeper struct {
+	mutex     sync.Mutex
+	nodes     map[string][]byte
+	versions  map[string]int32
+	sequences map[string]int
+}
+
+func newFakeZookeeper() *fakeZookeeper {
+	return &fakeZookeeper{
+		nodes:     map[string][]byte{"/": nil},
+		versions:  map[string]int32{},
+		sequences: map[string]int{},
+	}
+}
+
+func (f *fakeZookeeper) Children(nodePath string) ([]string, *zk.Stat, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, ok := f.nodes[nodePath]; !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	var children []string
+	for child := range f.nodes {
+		if child != "/" && path.Dir(child) == nodePath {
+			children = append(children, path.Base(child))
+		}
+	}
+	return children, &zk.Stat{}, nil
+}
+
+func (f *fakeZookeeper) Get(nodePath string) ([]byte, *zk.Stat, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	data, ok := f.nodes[nodePath]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	return data, &zk.Stat{Version: f.versions[nodePath]}, nil
+}
+
+func (f *fakeZookeeper) Set(nodePath string, data []byte, version int32) (*zk.Stat, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, ok := f.nodes[nodePath]; !ok {
+		return nil, zk.ErrNoNode
+	}
+	if version != -1 && version != f.versions[nodePath] {
+		return nil, zk.ErrBadVersion
+	}
+	f.nodes[nodePath] = data
+	f.versions[nodePath]++
+	return &zk.Stat{Version: f.versions[nodePath]}, nil
+}
+
+func (f *fakeZookeeper) Create(nodePath string, data []byte, flags int32, acl []zk.ACL) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	parent := path.Dir(nodePath)
+	if _, ok := f.nodes[parent]; !ok {
+		return "", zk.ErrNoNode
+	}
+	if flags&zk.FlagSequence != 0 {
+		nodePath += fmt.Sprintf("%010d", f.sequences[parent])
+		f.sequences[parent]++
+	}
+	if _, ok := f.nodes[nodePath]; ok {
+		return "", zk.ErrNodeExists
+	}
+	f.nodes[nodePath] = data
+	return nodePath, nil
+}
+
+// TestZookeeperWorkerIdAssigner Runs against an in-memory ZooKeeper, or a local server such as UID_TEST_ZOOKEEPER=127.0.0.1:2181
+func TestZookeeperWorkerIdAssigner(t *testing.T) {
+	var conn zookeeperConn = newFakeZookeeper()
+	if server := os.Getenv("UID_TEST_ZOOKEEPER"); server != "" {
+		zkConn, _, err := zk.Connect([]string{server}, 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zkConn.Close()
+		conn = zkConn
+	}
+	ctx := context.Background()
+	leafName := "uid-generator-test-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	// a machine migrated from Leaf keeps its worker id
+	if _, err := conn.Create("/snowflake", nil, 0, zk.WorldACL(zk.PermAll)); err != nil && err != zk.ErrNodeExists {
+		t.Fatal(err)
+	}
+	if _, err := conn.Create("/snowflake/"+leafName, nil, 0, zk.WorldACL(zk.PermAll)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Create("/snowflake/"+leafName+"/forever", nil, 0, zk.WorldACL(zk.PermAll)); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(leafEndpoint{Ip: "10.0.0.1", Port: "8080", Timestamp: time.Now().UnixMilli()})
+	if _, err := conn.Create("/snowflake/"+leafName+"/forever/10.0.0.1:8080-0000000007", data, 0, zk.WorldACL(zk.PermAll)); err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := newZookeeperWorkerIdAssigner(conn, leafName, "8080", WithZookeeperIp("10.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workerLease, err := leaf.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workerLease.WorkerId() != 7 {
+		t.Errorf("worker id is %d, want 7 created by Leaf", workerLease.WorkerId())
+	}
+
+	// a new machine creates a sequential znode
+	assigner, err := newZookeeperWorkerIdAssigner(conn, leafName, "8080", WithZookeeperIp("10.0.0.2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := assigner.AssignWorkerId(canceled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err is %v, want context.Canceled", err)
+	}
+	workerLease, err = assigner.AssignWorkerId(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workerLease.WorkerId() == 7 {
+		t.Error("worker id of another machine is reused")
+	}
+	children, _, err := conn.Children("/snowflake/" + leafName + "/forever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != 2 {
+		t.Errorf("znodes are %v, want the one of Leaf and the new one", children)
+	}
+
+	// the last timestamp is kept in the data of the znode
+	lastTimestamp := time.Now().Add(time.Hour)
+	if err := assigner.SaveLastTimestamp(ctx, workerLease.WorkerId(), lastTimestamp); err != nil {
+		t.Fatal(err)
+	}
+	if err := assigner.SaveLastTimestamp(ctx, workerLease.WorkerId(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := assigner.LoadLastTimestamp(ctx, workerLease.WorkerId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.UnixMilli() != lastTimestamp.UnixMilli() {
+		t.Errorf("last timestamp is %v, want %v", loaded, lastTimestamp)
+	}
+}